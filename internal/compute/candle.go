@@ -0,0 +1,84 @@
+package compute
+
+import (
+	"time"
+
+	"github.com/tupyy/vwap/internal/entity"
+	"github.com/tupyy/vwap/internal/log"
+)
+
+// CandleBuilder aggregates the tickers of a product into OHLCV bars of a fixed interval.
+// Bars are aligned on the interval (e.g. 1m bars start at the beginning of each minute) and keyed by the timestamp of the tickers.
+// A bar is closed by the first ticker of a following bar so intervals without any trade do not produce a bar.
+type CandleBuilder struct {
+	interval time.Duration
+	// current -- the bar being built. Nil before the first ticker.
+	current *entity.Candle
+	// sums -- running sums of the current bar used to compute its vwap
+	sums vwapSums
+}
+
+func NewCandleBuilder(interval time.Duration) *CandleBuilder {
+	return &CandleBuilder{
+		interval: interval,
+	}
+}
+
+// Add adds the ticker to its bar. It returns the bar closed by the ticker, if any.
+// Tickers of an already closed bar are ignored.
+func (b *CandleBuilder) Add(t entity.Ticker) (closed entity.Candle, found bool) {
+	start := t.Timestamp.Truncate(b.interval)
+
+	if b.current != nil {
+		switch {
+		case start.Before(b.current.Start):
+			log.GetLogger().Debugf("ticker %+v belongs to a closed %s bar. ignored", t, b.interval)
+
+			return entity.Candle{}, false
+		case start.After(b.current.Start):
+			closed, found = *b.current, true
+			b.current = nil
+		}
+	}
+
+	if b.current == nil {
+		b.current = &entity.Candle{
+			ProductID: t.ProductID,
+			Interval:  b.interval,
+			Start:     start,
+			Open:      t.Price,
+			High:      t.Price,
+			Low:       t.Price,
+		}
+		b.sums = vwapSums{}
+	}
+
+	if t.Price.Cmp(b.current.High) > 0 {
+		b.current.High = t.Price
+	}
+
+	if t.Price.Cmp(b.current.Low) < 0 {
+		b.current.Low = t.Price
+	}
+
+	b.sums.Add(entity.DataPoint{Value: t.Price, Volume: t.Volume})
+
+	b.current.Close = t.Price
+	b.current.Volume = b.sums.totalVolume
+	b.current.VWAP = b.sums.Average()
+	b.current.TradeCount++
+
+	return closed, found
+}
+
+// Flush returns the bar being built, if any, and closes it. The next ticker opens a new bar.
+func (b *CandleBuilder) Flush() (entity.Candle, bool) {
+	if b.current == nil {
+		return entity.Candle{}, false
+	}
+
+	closed := *b.current
+	b.current = nil
+
+	return closed, true
+}
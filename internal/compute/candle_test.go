@@ -0,0 +1,72 @@
+package compute_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/compute"
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestCandleBuilder(t *testing.T) {
+	b := compute.NewCandleBuilder(time.Minute)
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+
+	ticker := func(price, volume string, ts time.Time) entity.Ticker {
+		return entity.Ticker{
+			ProductID: "BTC-USD",
+			Price:     entity.MustParseDecimal(price),
+			Volume:    entity.MustParseDecimal(volume),
+			Timestamp: ts,
+		}
+	}
+
+	for i, tc := range []entity.Ticker{
+		ticker("10", "1", start.Add(5*time.Second)),
+		ticker("12", "1", start.Add(10*time.Second)),
+		ticker("8", "2", start.Add(20*time.Second)),
+		ticker("11", "1", start.Add(59*time.Second)),
+	} {
+		_, closed := b.Add(tc)
+		assert.False(t, closed, "ticker %d should not close the bar", i)
+	}
+
+	// a ticker of the next bar closes the first one
+	candle, closed := b.Add(ticker("20", "1", start.Add(61*time.Second)))
+	assert.True(t, closed, "the bar should be closed")
+	assert.Equal(t, entity.Candle{
+		ProductID:  "BTC-USD",
+		Interval:   time.Minute,
+		Start:      start,
+		Open:       entity.DecimalFromInt(10),
+		High:       entity.DecimalFromInt(12),
+		Low:        entity.DecimalFromInt(8),
+		Close:      entity.DecimalFromInt(11),
+		Volume:     entity.DecimalFromInt(5),
+		VWAP:       entity.MustParseDecimal("9.8"),
+		TradeCount: 4,
+	}, candle)
+
+	// a late ticker of the closed bar is ignored
+	_, closed = b.Add(ticker("1", "1", start.Add(30*time.Second)))
+	assert.False(t, closed, "the late ticker should be ignored")
+
+	// no bar is written for the minutes without trade
+	candle, closed = b.Add(ticker("30", "1", start.Add(5*time.Minute)))
+	assert.True(t, closed, "the bar should be closed")
+	assert.Equal(t, start.Add(time.Minute), candle.Start)
+	assert.Equal(t, "20", candle.Open.String())
+	assert.Equal(t, 1, candle.TradeCount)
+
+	// the bar being built is returned on flush
+	candle, closed = b.Flush()
+	assert.True(t, closed, "the bar should be flushed")
+	assert.Equal(t, start.Add(5*time.Minute), candle.Start)
+	assert.Equal(t, "30", candle.Close.String())
+
+	_, closed = b.Flush()
+	assert.False(t, closed, "no bar is left after flush")
+}
@@ -0,0 +1,155 @@
+package compute
+
+import (
+	"math"
+	"time"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+// DecayCalculator computes an exponentially decaying volume weighted average.
+// The weight of a point is its volume multiplied by 2^(-age/halfLife) where age is measured with the timestamp of the points.
+// The points are not stored: at each point the sums are decayed by the time elapsed since the previous point
+// so the update is O(1) and the average moves smoothly instead of jumping when a point falls off a window.
+type DecayCalculator struct {
+	// halfLife is the age at which the weight of a point is halved
+	halfLife time.Duration
+	// lastTimestamp is the timestamp the sums are decayed to
+	lastTimestamp time.Time
+	// weightSum is the Sum(weight * volume) of all points
+	weightSum float64
+	// mean is the weighted average Sum(weight * volume * value) / weightSum of all points
+	mean float64
+	// squaredDeviationSum is the Sum(weight * volume * (value - mean)^2) of all points.
+	// It is updated incrementally (West's algorithm) instead of being derived from Sum(weight * volume * value^2)
+	// which cancels out when the deviation is tiny compared to the price.
+	squaredDeviationSum float64
+	// buyWeightSum and buyValueWeightSum are the sums of the buy points
+	buyWeightSum      float64
+	buyValueWeightSum float64
+	// sellWeightSum and sellValueWeightSum are the sums of the sell points
+	sellWeightSum      float64
+	sellValueWeightSum float64
+	// totalPoints is the number of points added
+	totalPoints int
+	// firstTimestamp is the timestamp of the oldest point
+	firstTimestamp time.Time
+}
+
+func NewDecayCalculator(halfLife time.Duration) *DecayCalculator {
+	return &DecayCalculator{
+		halfLife: halfLife,
+	}
+}
+
+func (c *DecayCalculator) Add(p entity.DataPoint) {
+	weight := p.Volume.Float64()
+
+	if c.totalPoints == 0 || p.Timestamp.Before(c.firstTimestamp) {
+		c.firstTimestamp = p.Timestamp
+	}
+
+	if p.Timestamp.After(c.lastTimestamp) {
+		// decay the sums to the timestamp of the new point
+		factor := c.decay(p.Timestamp.Sub(c.lastTimestamp))
+
+		c.weightSum *= factor
+		c.squaredDeviationSum *= factor
+		c.buyWeightSum *= factor
+		c.buyValueWeightSum *= factor
+		c.sellWeightSum *= factor
+		c.sellValueWeightSum *= factor
+		c.lastTimestamp = p.Timestamp
+	} else {
+		// late point: it is decayed to the timestamp of the sums
+		weight *= c.decay(c.lastTimestamp.Sub(p.Timestamp))
+	}
+
+	value := p.Value.Float64()
+
+	switch {
+	case weight <= 0:
+	case c.weightSum <= 0:
+		// first point or all the previous points decayed away: the mean is set exactly so there is no rounding to deviate from
+		c.weightSum = weight
+		c.mean = value
+		c.squaredDeviationSum = 0
+	default:
+		c.weightSum += weight
+		delta := value - c.mean
+		c.mean += delta * weight / c.weightSum
+		c.squaredDeviationSum += weight * delta * (value - c.mean)
+	}
+
+	c.totalPoints++
+
+	switch p.Side {
+	case entity.Buy:
+		c.buyWeightSum += weight
+		c.buyValueWeightSum += weight * value
+	case entity.Sell:
+		c.sellWeightSum += weight
+		c.sellValueWeightSum += weight * value
+	}
+}
+
+// ComputeAverage returns the decayed average and the number of points added. It returns 0 if there is no weight left.
+func (c *DecayCalculator) ComputeAverage() (avg entity.Decimal, totalPoints int) {
+	if c.weightSum <= 0 {
+		return entity.Decimal{}, c.totalPoints
+	}
+
+	return entity.DecimalFromFloat(c.mean), c.totalPoints
+}
+
+// ComputeStdDev returns the decayed volume weighted standard deviation. It returns 0 if there is no weight left.
+func (c *DecayCalculator) ComputeStdDev() entity.Decimal {
+	if c.weightSum <= 0 {
+		return entity.Decimal{}
+	}
+
+	variance := c.squaredDeviationSum / c.weightSum
+	if variance <= 0 {
+		return entity.Decimal{}
+	}
+
+	return entity.DecimalFromFloat(math.Sqrt(variance))
+}
+
+// ComputeOrderFlow returns the decayed averages and volumes of the buy and sell points.
+func (c *DecayCalculator) ComputeOrderFlow() entity.OrderFlow {
+	var buyAverage, sellAverage entity.Decimal
+
+	if c.buyWeightSum > 0 {
+		buyAverage = entity.DecimalFromFloat(c.buyValueWeightSum / c.buyWeightSum)
+	}
+
+	if c.sellWeightSum > 0 {
+		sellAverage = entity.DecimalFromFloat(c.sellValueWeightSum / c.sellWeightSum)
+	}
+
+	return orderFlow(buyAverage, sellAverage, entity.DecimalFromFloat(c.buyWeightSum), entity.DecimalFromFloat(c.sellWeightSum))
+}
+
+// ComputeStats returns the number of points added, the decayed volume and the timestamps of the oldest and newest points.
+func (c *DecayCalculator) ComputeStats() entity.WindowStats {
+	if c.totalPoints == 0 {
+		return entity.WindowStats{}
+	}
+
+	return entity.WindowStats{
+		TotalPoints: c.totalPoints,
+		Volume:      entity.DecimalFromFloat(c.weightSum),
+		Start:       c.firstTimestamp,
+		End:         c.lastTimestamp,
+	}
+}
+
+// decay returns the factor 2^(-age/halfLife).
+func (c *DecayCalculator) decay(age time.Duration) float64 {
+	if c.halfLife <= 0 {
+		return 0
+	}
+
+	return math.Exp2(-float64(age) / float64(c.halfLife))
+}
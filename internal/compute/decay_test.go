@@ -0,0 +1,56 @@
+package compute_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/compute"
+)
+
+func TestDecayCalculator(t *testing.T) {
+	calc := compute.NewDecayCalculator(time.Minute)
+
+	now := time.Now()
+
+	calc.Add(timedPoint("10", "1", now))
+
+	avg, totalPoints := calc.ComputeAverage()
+	assert.Equal(t, "10", avg.String(), "expect avg = 10")
+	assert.Equal(t, 1, totalPoints, "expect 1 computation point")
+
+	// after one half-life the first point weights 0.5: (10*0.5 + 40*1) / 1.5 = 30
+	calc.Add(timedPoint("40", "1", now.Add(time.Minute)))
+
+	avg, totalPoints = calc.ComputeAverage()
+	assert.Equal(t, "30", avg.String(), "expect avg = 30")
+	assert.Equal(t, 2, totalPoints, "expect 2 computation points")
+
+	// variance = (100*0.5 + 1600) / 1.5 - 30^2 = 200
+	assert.InDelta(t, 14.14213562, calc.ComputeStdDev().Float64(), 1e-8)
+
+	// a late point is decayed to the newest timestamp: (10*0.5 + 40 + 20*0.5) / 2 = 27.5
+	calc.Add(timedPoint("20", "1", now))
+
+	avg, _ = calc.ComputeAverage()
+	assert.Equal(t, "27.5", avg.String(), "expect avg = 27.5")
+
+	// after a long time the old points do not count anymore
+	calc.Add(timedPoint("50", "1", now.Add(24*time.Hour)))
+
+	avg, _ = calc.ComputeAverage()
+	assert.Equal(t, "50", avg.String(), "expect avg = 50")
+}
+
+func TestDecayCalculatorStdDevHighPrice(t *testing.T) {
+	calc := compute.NewDecayCalculator(time.Minute)
+
+	now := time.Now()
+
+	calc.Add(timedPoint("65432.12", "0.3", now))
+	calc.Add(timedPoint("65432.12", "0.3", now))
+	calc.Add(timedPoint("65432.13", "0.3", now))
+
+	assert.InDelta(t, 0.00471405, calc.ComputeStdDev().Float64(), 1e-8)
+}
@@ -4,11 +4,66 @@ package compute
 
 This package computes the volume average of points.
 
-stack.go implements a LILO stack
+ring.go implements a FIFO queue backed by a preallocated ring buffer. Both push and pop are O(1) and do not allocate while the ring has room.
+The ring of a count window is preallocated to its size and never grows. The time and volume windows start with DefaultVolumeSize points and
+double the buffer each time a point is pushed into a full ring, so they allocate until the ring fits the largest number of points held by the window.
+A late point of a time window is inserted in timestamp order, shifting the newer points.
 
 vwap.go
 
-It computes the volume average. The computation is not O(n) but O(1). It keeps the total volume of all points in the stack as variable
-as well the sum of products value*volume for all points in the stack. If the stack is full, when a new point is added, it substract the popped point
-from total volume and the sum of product and adds the new one keeping in this way the two variable consistent with the content of the stack.
+It computes the volume average. The computation is not O(n) but O(1). It keeps the total volume of all points in the window as variable
+as well the sum of products value*volume for all points in the window. If the window is full, when a new point is added, it substract the popped point
+from total volume and the sum of product and adds the new one keeping in this way the two variable consistent with the content of the window.
+The window can be defined by a number of points, by a duration or by a traded volume. With a duration, the points older than the duration relative to
+the newest point are popped using the timestamp of the ticker. A late point still within the duration is inserted in timestamp order so it is
+evicted with the points of its time. With a volume, the oldest points are popped until the window holds the volume
+and the last one is split: its volume is reduced to the part still covered by the window.
+
+The points may be tagged with the venue of their trade: the calculator then keeps the sums of each venue so the contribution of each venue
+to a consolidated window is known as points are evicted or split.
+
+quantile.go
+
+It keeps the prices of a window in a treap weighted by volume. Each node holds the volume of its subtree so the volume weighted
+median and percentiles are found in O(log n) while points are added and evicted, without sorting the window.
+
+session.go
+
+It computes the volume average of all the points since the last anchor of a cron-like schedule (schedule.go). The sums are reset when
+a point falls after the next anchor and the average of the closed session is kept until it is popped by TradingPairAvgCalculator.
+
+decay.go
+
+It computes an exponentially decaying volume average. The points are not stored: the sums are decayed by the time elapsed
+since the previous point each time a point is added.
+
+filter.go
+
+The filters drop the tickers before they are processed: dust trades below a minimum volume and outliers far from the median price
+(in median absolute deviations) or from the vwap of the last accepted trades.
+
+sequence.go
+
+It rejects the tickers older than the last heartbeat. The gaps in the trades are detected by the manager which calls MarkGap:
+after a gap, TradingPairAvgCalculator can mark the results of a horizon degraded until its window holds only points received after the gap.
+
+indicator.go
+
+It defines the Indicator interface: an indicator consumes the tickers and heartbeats of a product and returns named values of typed kinds.
+The indicators are registered by type so they are chosen by name in the configuration. ema and rsi are built in.
+The manager processes the indicators through this interface. The averages are not indicators: they are the horizons of the calculators.
+
+snapshot.go
+
+The calculators save their state as json to be restored on restart. The windows are saved as their points and the exact sums are recomputed
+when the points are added back. The session and decaying horizons do not store their points so their sums are saved as they are.
+
+sum.go
+
+Values and volumes are fixed-point decimals (see entity.Decimal) so the sums are exact integers: the total volume fits in 64 bits and
+the sum of products value*volume, which has twice as many decimal places, is kept in 128 bits. Repeatedly adding and substracting
+points does not make the average drift. As a self-check, every DefaultDriftCheckInterval points (and never more often than the size
+of the window) the calculator recomputes the sums from the window, logs the observed drift and resynchronizes the running sums.
+The sum of value^2*volume used for the standard deviation has three times as many decimal places and is kept in 192 bits.
+The variance is computed from the exact sums with big integers so a deviation of a few cents on a high price does not cancel out.
 */
@@ -0,0 +1,166 @@
+package compute
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+// DefaultOutlierWindow is the default number of the last trades used as reference by OutlierFilter.
+const DefaultOutlierWindow = 100
+
+// minOutlierReference is the number of trades the reference window must hold before OutlierFilter rejects any trade.
+// It is lowered to the size of the window if the window is smaller.
+const minOutlierReference = 10
+
+// madScale turns the median absolute deviation into an estimate of the standard deviation of normally distributed prices.
+const madScale = 1.4826
+
+// ErrVolumeTooLow means that the size of the trade is lower than the minimum volume.
+var ErrVolumeTooLow = errors.New("volume too low")
+
+// ErrOutlier means that the price of the trade deviates too much from the last trades.
+var ErrOutlier = errors.New("outlier price")
+
+// VolumeFilter rejects the tickers whose size is lower than a minimum (e.g. dust trades).
+type VolumeFilter struct {
+	minVolume entity.Decimal
+	// rejected -- number of tickers rejected
+	rejected int
+}
+
+func NewVolumeFilter(minVolume entity.Decimal) *VolumeFilter {
+	return &VolumeFilter{
+		minVolume: minVolume,
+	}
+}
+
+// Check returns ErrVolumeTooLow if the size of the ticker is lower than the minimum volume.
+func (f *VolumeFilter) Check(t entity.Ticker) error {
+	if t.Volume.Cmp(f.minVolume) >= 0 {
+		return nil
+	}
+
+	f.rejected++
+
+	return fmt.Errorf("%w: %s < %s", ErrVolumeTooLow, t.Volume, f.minVolume)
+}
+
+// Rejected returns the number of tickers rejected.
+func (f *VolumeFilter) Rejected() int {
+	return f.rejected
+}
+
+// OutlierFilter rejects the fat-finger prints: the trades whose price deviates too much from the last trades or from the vwap of the pair.
+// A price is an outlier if it is more than maxMADs robust deviations from the median price of the reference window (the robust deviation is
+// the median absolute deviation scaled to a standard deviation) or more than maxPercent % from the current vwap of the pair (see SetAverage).
+// Every trade, rejected or not, is added to the reference window: an isolated print does not move the median but a genuine price move
+// is accepted once it makes the median of the window. Until the window holds minOutlierReference trades, the deviation from the median is not checked.
+type OutlierFilter struct {
+	// reference -- the last trades
+	reference *Calculator
+	// maxMADs -- maximum number of robust deviations from the median. Zero disables the check.
+	maxMADs float64
+	// maxPercent -- maximum deviation from the vwap in percent. Zero disables the check.
+	maxPercent float64
+	// average -- current vwap of the pair. Zero until set.
+	average entity.Decimal
+	// minReference -- number of trades the reference window must hold before any trade is rejected
+	minReference int
+	// prices -- scratch buffer used to compute the medians
+	prices []int64
+	// rejected -- number of tickers rejected
+	rejected int
+}
+
+// NewOutlierFilter returns a filter using the last window trades as reference.
+func NewOutlierFilter(window int, maxMADs, maxPercent float64) *OutlierFilter {
+	minReference := minOutlierReference
+	if window < minReference {
+		minReference = window
+	}
+
+	return &OutlierFilter{
+		reference:    NewCalculator(window),
+		maxMADs:      maxMADs,
+		maxPercent:   maxPercent,
+		minReference: minReference,
+		prices:       make([]int64, 0, window),
+	}
+}
+
+// Check returns ErrOutlier if the price of the ticker deviates too much from the reference window or from the vwap of the pair.
+// The ticker is added to the reference window in both cases.
+func (f *OutlierFilter) Check(t entity.Ticker) error {
+	err := f.check(t.Price)
+	if err != nil {
+		f.rejected++
+	}
+
+	f.reference.Add(entity.DataPoint{Value: t.Price, Volume: t.Volume, Timestamp: t.Timestamp})
+
+	return err
+}
+
+// SetAverage sets the current vwap of the pair used by the percent check. The check is skipped while the average is zero.
+func (f *OutlierFilter) SetAverage(avg entity.Decimal) {
+	f.average = avg
+}
+
+// Rejected returns the number of tickers rejected.
+func (f *OutlierFilter) Rejected() int {
+	return f.rejected
+}
+
+func (f *OutlierFilter) check(price entity.Decimal) error {
+	if f.maxPercent > 0 && !f.average.IsZero() {
+		deviation := 100 * math.Abs(price.Sub(f.average).Float64()) / f.average.Float64()
+		if deviation > f.maxPercent {
+			return fmt.Errorf("%w: %s is %.2f%% from the vwap %s", ErrOutlier, price, deviation, f.average)
+		}
+	}
+
+	if f.maxMADs > 0 && f.reference.window.Size() >= f.minReference {
+		median, mad := f.medianAbsoluteDeviation()
+
+		// all the prices are the same: the deviation cannot be estimated
+		if mad > 0 {
+			deviations := math.Abs(float64(price.Units()-median)) / (madScale * float64(mad))
+			if deviations > f.maxMADs {
+				return fmt.Errorf("%w: %s is %.2f robust deviations from the median %s", ErrOutlier, price, deviations, entity.NewDecimal(median))
+			}
+		}
+	}
+
+	return nil
+}
+
+// medianAbsoluteDeviation returns the median of the prices of the reference window and the median of their absolute deviation from it, in units.
+func (f *OutlierFilter) medianAbsoluteDeviation() (median, mad int64) {
+	f.prices = f.prices[:0]
+	f.reference.window.Do(func(p entity.DataPoint) {
+		f.prices = append(f.prices, p.Value.Units())
+	})
+
+	median = medianInPlace(f.prices)
+
+	for i, p := range f.prices {
+		if p < median {
+			f.prices[i] = median - p
+		} else {
+			f.prices[i] = p - median
+		}
+	}
+
+	return median, medianInPlace(f.prices)
+}
+
+// medianInPlace sorts values and returns their median. The median of an even number of values is the lower middle value.
+func medianInPlace(values []int64) int64 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	return values[(len(values)-1)/2]
+}
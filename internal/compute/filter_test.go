@@ -0,0 +1,76 @@
+package compute_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/compute"
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestOutlierFilterMAD(t *testing.T) {
+	f := compute.NewOutlierFilter(10, 5, 0)
+
+	ticker := func(price string) entity.Ticker {
+		return entity.Ticker{Price: entity.MustParseDecimal(price), Volume: entity.DecimalFromInt(1)}
+	}
+
+	// the reference window is not full yet: everything is accepted
+	assert.Nil(t, f.Check(ticker("1000")))
+
+	for _, p := range []string{"99", "100", "101", "100", "99", "101", "100", "102", "98", "100"} {
+		assert.Nil(t, f.Check(ticker(p)))
+	}
+
+	// median 100, mad 1: 5 robust deviations are 7.413
+	assert.Nil(t, f.Check(ticker("107")))
+	assert.ErrorIs(t, f.Check(ticker("108")), compute.ErrOutlier)
+	assert.ErrorIs(t, f.Check(ticker("1")), compute.ErrOutlier)
+	assert.Equal(t, 2, f.Rejected())
+}
+
+func TestOutlierFilterPriceMove(t *testing.T) {
+	f := compute.NewOutlierFilter(10, 5, 0)
+
+	ticker := func(price int64) entity.Ticker {
+		return entity.Ticker{Price: entity.DecimalFromInt(price), Volume: entity.DecimalFromInt(1)}
+	}
+
+	for _, p := range []int64{98, 99, 100, 101, 102, 98, 99, 100, 101, 102} {
+		assert.Nil(t, f.Check(ticker(p)))
+	}
+
+	// the price moves to 110: the first trades are rejected but they are added to the reference window which follows the move
+	var err error
+	for i := 0; i < 10; i++ {
+		err = f.Check(ticker(110))
+	}
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, f.Rejected())
+}
+
+func TestOutlierFilterPercent(t *testing.T) {
+	f := compute.NewOutlierFilter(3, 0, 10)
+
+	ticker := func(price int64) entity.Ticker {
+		return entity.Ticker{Price: entity.DecimalFromInt(price), Volume: entity.DecimalFromInt(1)}
+	}
+
+	// no vwap yet
+	assert.Nil(t, f.Check(ticker(200)))
+
+	f.SetAverage(entity.DecimalFromInt(100))
+	assert.Nil(t, f.Check(ticker(110)))
+	assert.ErrorIs(t, f.Check(ticker(89)), compute.ErrOutlier)
+	assert.Equal(t, 1, f.Rejected())
+}
+
+func TestVolumeFilter(t *testing.T) {
+	f := compute.NewVolumeFilter(entity.MustParseDecimal("0.01"))
+
+	assert.Nil(t, f.Check(entity.Ticker{Volume: entity.MustParseDecimal("0.01")}))
+	assert.ErrorIs(t, f.Check(entity.Ticker{Volume: entity.MustParseDecimal("0.001")}), compute.ErrVolumeTooLow)
+	assert.Equal(t, 1, f.Rejected())
+}
@@ -0,0 +1,232 @@
+package compute
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+// ErrUnknownIndicator is returned when no indicator is registered under a name.
+var ErrUnknownIndicator = errors.New("unknown indicator")
+
+// Indicator computes named values from the tickers of a product. The indicators are output only: their values are written
+// along with the averages but are not used by the cross rates, the arbitrage monitor or the consolidated pairs, which read the
+// horizons of the calculators. A new average belongs to the calculators, not to the indicators.
+type Indicator interface {
+	// Name returns the name of the indicator reported in the results.
+	Name() string
+	ProcessHeartBeat(h entity.HeartBeat)
+	// ProcessTicker returns the values of the indicator after the ticker.
+	ProcessTicker(t entity.Ticker) ([]entity.IndicatorValue, error)
+}
+
+// IndicatorFactory returns an indicator named name. params is the json object of the parameters of the indicator. It may be empty.
+type IndicatorFactory func(name string, params json.RawMessage) (Indicator, error)
+
+// indicators holds the registered indicators. The key is the type of the indicator.
+var indicators = map[string]IndicatorFactory{
+	"ema": newEMAIndicator,
+	"rsi": newRSIIndicator,
+}
+
+// RegisterIndicator registers an indicator type so it can be chosen by name in the configuration.
+// It replaces the indicator already registered with the same type. It is not safe for concurrent use: indicators are registered at startup.
+func RegisterIndicator(kind string, f IndicatorFactory) {
+	indicators[kind] = f
+}
+
+// Indicators returns the registered indicator types, sorted.
+func Indicators() []string {
+	kinds := make([]string, 0, len(indicators))
+	for k := range indicators {
+		kinds = append(kinds, k)
+	}
+
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+// NewIndicator returns an indicator of the registered type kind. The name defaults to the type.
+func NewIndicator(kind, name string, params json.RawMessage) (Indicator, error) {
+	f, found := indicators[kind]
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownIndicator, kind)
+	}
+
+	if len(name) == 0 {
+		name = kind
+	}
+
+	i, err := f(name, params)
+	if err != nil {
+		return nil, fmt.Errorf("indicator %s: %w", name, err)
+	}
+
+	return i, nil
+}
+
+// decodeParams decodes the parameters of an indicator. Unknown parameters are rejected.
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+
+	return nil
+}
+
+// EMAIndicator is the exponential moving average of the prices with a smoothing factor 2/(period+1).
+// It is not ready until period prices were added.
+type EMAIndicator struct {
+	sequenceGuard
+	name   string
+	period int
+	alpha  float64
+	ema    float64
+	count  int
+}
+
+type periodParams struct {
+	Period int `json:"period,omitempty"`
+}
+
+// defaultIndicatorPeriod is the period of the ema and rsi indicators without period parameter.
+const defaultIndicatorPeriod = 14
+
+func parsePeriod(params json.RawMessage) (int, error) {
+	p := periodParams{Period: defaultIndicatorPeriod}
+	if err := decodeParams(params, &p); err != nil {
+		return 0, err
+	}
+
+	if p.Period <= 0 {
+		return 0, fmt.Errorf("period must be positive")
+	}
+
+	return p.Period, nil
+}
+
+func newEMAIndicator(name string, params json.RawMessage) (Indicator, error) {
+	period, err := parsePeriod(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EMAIndicator{name: name, period: period, alpha: 2 / float64(period+1)}, nil
+}
+
+func (e *EMAIndicator) Name() string {
+	return e.name
+}
+
+func (e *EMAIndicator) ProcessTicker(t entity.Ticker) ([]entity.IndicatorValue, error) {
+	if err := e.checkTicker(t); err != nil {
+		return nil, err
+	}
+
+	price := t.Price.Float64()
+
+	if e.count == 0 {
+		e.ema = price
+	} else {
+		e.ema += e.alpha * (price - e.ema)
+	}
+
+	e.count++
+
+	return []entity.IndicatorValue{
+		entity.NewDecimalValue("ema", entity.DecimalFromFloat(e.ema)),
+		entity.NewBoolValue("ready", e.count >= e.period),
+	}, nil
+}
+
+// RSIIndicator is the relative strength index of the price changes between consecutive tickers with the Wilder smoothing.
+// The average gain and loss are the simple averages of the first period changes, then each change is weighted 1/period.
+// It goes from 0 (only losses) to 100 (only gains) and is not ready until period changes were seen.
+type RSIIndicator struct {
+	sequenceGuard
+	name      string
+	period    int
+	lastPrice float64
+	avgGain   float64
+	avgLoss   float64
+	// changes is the number of price changes seen
+	changes  int
+	hasPrice bool
+}
+
+func newRSIIndicator(name string, params json.RawMessage) (Indicator, error) {
+	period, err := parsePeriod(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RSIIndicator{name: name, period: period}, nil
+}
+
+func (r *RSIIndicator) Name() string {
+	return r.name
+}
+
+func (r *RSIIndicator) ProcessTicker(t entity.Ticker) ([]entity.IndicatorValue, error) {
+	if err := r.checkTicker(t); err != nil {
+		return nil, err
+	}
+
+	price := t.Price.Float64()
+
+	if r.hasPrice {
+		r.addChange(price - r.lastPrice)
+	}
+
+	r.lastPrice = price
+	r.hasPrice = true
+
+	return []entity.IndicatorValue{
+		entity.NewFloatValue("rsi", r.value()),
+		entity.NewBoolValue("ready", r.changes >= r.period),
+	}, nil
+}
+
+func (r *RSIIndicator) addChange(change float64) {
+	var gain, loss float64
+	if change > 0 {
+		gain = change
+	} else {
+		loss = -change
+	}
+
+	r.changes++
+
+	// simple average of the first changes, then Wilder smoothing
+	n := float64(r.period)
+	if r.changes <= r.period {
+		n = float64(r.changes)
+	}
+
+	r.avgGain += (gain - r.avgGain) / n
+	r.avgLoss += (loss - r.avgLoss) / n
+}
+
+// value returns 100 - 100/(1 + avgGain/avgLoss). It is 50 if the price never changed.
+func (r *RSIIndicator) value() float64 {
+	switch {
+	case r.avgLoss == 0 && r.avgGain == 0:
+		return 50
+	case r.avgLoss == 0:
+		return 100
+	}
+
+	return 100 - 100/(1+r.avgGain/r.avgLoss)
+}
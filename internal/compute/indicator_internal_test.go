@@ -0,0 +1,27 @@
+package compute
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterIndicator(t *testing.T) {
+	registered := make(map[string]IndicatorFactory, len(indicators))
+	for k, f := range indicators {
+		registered[k] = f
+	}
+
+	t.Cleanup(func() { indicators = registered })
+
+	RegisterIndicator("last", func(name string, params json.RawMessage) (Indicator, error) {
+		return NewIndicator("ema", name, json.RawMessage(`{"period": 1}`))
+	})
+
+	assert.Contains(t, Indicators(), "last")
+
+	i, err := NewIndicator("last", "last price", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "last price", i.Name())
+}
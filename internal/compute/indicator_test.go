@@ -0,0 +1,71 @@
+package compute_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/compute"
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestNewIndicator(t *testing.T) {
+	i, err := compute.NewIndicator("ema", "", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "ema", i.Name(), "the name should default to the type")
+
+	_, err = compute.NewIndicator("macd", "", nil)
+	assert.ErrorIs(t, err, compute.ErrUnknownIndicator)
+
+	_, err = compute.NewIndicator("rsi", "", json.RawMessage(`{"length": 14}`))
+	assert.NotNil(t, err, "unknown params should be rejected")
+
+	_, err = compute.NewIndicator("ema", "", json.RawMessage(`{"period": 0}`))
+	assert.NotNil(t, err, "the period should be positive")
+
+	_, err = compute.NewIndicator("vwap", "", nil)
+	assert.ErrorIs(t, err, compute.ErrUnknownIndicator, "the vwap is a horizon, not an indicator")
+}
+
+func TestEMAIndicator(t *testing.T) {
+	i, err := compute.NewIndicator("ema", "ema-3", json.RawMessage(`{"period": 3}`))
+	assert.Nil(t, err)
+
+	var values []entity.IndicatorValue
+
+	// alpha = 2 / (3 + 1) = 0.5: 1, 1.5, 2.25
+	for _, price := range []int64{1, 2, 3} {
+		values, err = i.ProcessTicker(entity.Ticker{Price: entity.DecimalFromInt(price), Volume: entity.DecimalFromInt(1)})
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, []entity.IndicatorValue{
+		entity.NewDecimalValue("ema", entity.MustParseDecimal("2.25")),
+		entity.NewBoolValue("ready", true),
+	}, values)
+
+	i.ProcessHeartBeat(entity.HeartBeat{Sequence: 10})
+
+	_, err = i.ProcessTicker(entity.Ticker{Sequence: 4, Price: entity.DecimalFromInt(4), Volume: entity.DecimalFromInt(1)})
+	assert.ErrorIs(t, err, compute.ErrSequenceNotIncreasing)
+}
+
+func TestRSIIndicator(t *testing.T) {
+	i, err := compute.NewIndicator("rsi", "", json.RawMessage(`{"period": 2}`))
+	assert.Nil(t, err)
+
+	values, _ := i.ProcessTicker(entity.Ticker{Price: entity.DecimalFromInt(10)})
+	assert.Equal(t, entity.NewFloatValue("rsi", 50), values[0], "no change yet")
+	assert.Equal(t, entity.NewBoolValue("ready", false), values[1])
+
+	// changes +2 and -1: avg gain 1, avg loss 0.5, rsi = 100 - 100 / 3
+	_, _ = i.ProcessTicker(entity.Ticker{Price: entity.DecimalFromInt(12)})
+	values, _ = i.ProcessTicker(entity.Ticker{Price: entity.DecimalFromInt(11)})
+	assert.InDelta(t, 100-100.0/3, values[0].Float, 1e-9)
+	assert.Equal(t, entity.NewBoolValue("ready", true), values[1])
+
+	// wilder smoothing: change -1, avg gain 0.5, avg loss 0.75, rsi = 40
+	values, _ = i.ProcessTicker(entity.Ticker{Price: entity.DecimalFromInt(10)})
+	assert.InDelta(t, 40, values[0].Float, 1e-9)
+}
@@ -2,7 +2,7 @@ package compute
 
 import (
 	"errors"
-	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/tupyy/vwap/internal/entity"
@@ -16,43 +16,313 @@ const DefaultVolumeSize = 200
 // It means that the message arrive too late and is not taken into account.
 var ErrSequenceNotIncreasing = errors.New("error sequence not increasing")
 
+// Averager computes the volume weighted average and standard deviation of the points added to it.
+type Averager interface {
+	Add(p entity.DataPoint)
+	ComputeAverage() (avg entity.Decimal, totalPoints int)
+	ComputeStdDev() entity.Decimal
+	ComputeOrderFlow() entity.OrderFlow
+	ComputeStats() entity.WindowStats
+}
+
+// WarmUp holds the thresholds a window must meet before its average is considered a reliable benchmark.
+// A zero threshold is always met.
+type WarmUp struct {
+	// MinPoints -- minimum number of points
+	MinPoints int
+	// MinVolume -- minimum total volume
+	MinVolume entity.Decimal
+	// MinSpan -- minimum time between the oldest and the newest point
+	MinSpan time.Duration
+}
+
+// Met returns true if the window described by stats meets all the thresholds.
+func (w WarmUp) Met(stats entity.WindowStats) bool {
+	if stats.TotalPoints < w.MinPoints || stats.Volume.Cmp(w.MinVolume) < 0 {
+		return false
+	}
+
+	// the span is only checked if required: the points of a count window may be out of order
+	return w.MinSpan <= 0 || stats.Span() >= w.MinSpan
+}
+
+// percentileAverager is implemented by the averagers which can compute the volume weighted percentiles of their points.
+type percentileAverager interface {
+	Averager
+	ComputePercentile(p float64) (entity.Decimal, bool)
+}
+
+// sessionAverager is implemented by the averagers which are reset at the end of each session.
+type sessionAverager interface {
+	Averager
+	SessionStart() time.Time
+	PopClosedSession() (Session, bool)
+}
+
+// horizon is a named window of the trading pair.
+type horizon struct {
+	name string
+	calc Averager
+	// windowed is false for the averagers whose points are never evicted (exponentially decaying).
+	// They cannot be marked degraded as their window never turns over.
+	windowed bool
+	// sinceGap is the number of points added since the last gap or -1 if the window holds no point older than the gap.
+	sinceGap int
+}
+
 type TradingPairAvgCalculator struct {
-	// c -- avg calculator
-	calc *Calculator
-	// heartBeatSequence -- holds the last received sequence
-	heartBeatSequence int64
+	sequenceGuard
+	// horizons -- avg calculators fed by the same tickers
+	horizons []horizon
 	// lastTimestamp -- holds the timestamp of the last message
 	lastTimestamp time.Time
+	// quoteIncrement -- the average is rounded to a multiple of quoteIncrement. Zero means no rounding.
+	quoteIncrement entity.Decimal
+	// bandMultipliers -- number of standard deviations of the bands around the average
+	bandMultipliers []float64
+	// median -- if true, the volume weighted median is reported
+	median bool
+	// percentiles -- volume weighted percentiles reported (from 0 to 100)
+	percentiles []float64
+	// warmUp -- the results are marked warming up until their window meets these thresholds
+	warmUp WarmUp
+	// markDegraded -- if true, the results are marked degraded after a gap until the window has turned over
+	markDegraded bool
+	// gap -- true if trades were lost since the last ticker
+	gap bool
 }
 
-func NewAvgCalculator(volumeSize int) *TradingPairAvgCalculator {
+// NewTradingPairAvgCalculator returns a calculator without any horizon. Horizons are added with AddHorizon.
+func NewTradingPairAvgCalculator() *TradingPairAvgCalculator {
 	return &TradingPairAvgCalculator{
-		calc: NewCalculator(volumeSize),
+		horizons: make([]horizon, 0, 1),
+	}
+}
+
+// NewAvgCalculator returns a calculator with one horizon of the last volumeSize tickers named after volumeSize.
+func NewAvgCalculator(volumeSize int) *TradingPairAvgCalculator {
+	c := NewTradingPairAvgCalculator()
+	c.AddHorizon(strconv.Itoa(volumeSize), NewCalculator(volumeSize))
+
+	return c
+}
+
+// NewTimeAvgCalculator returns a calculator with one horizon over the tickers of the last window duration named after window.
+func NewTimeAvgCalculator(window time.Duration) *TradingPairAvgCalculator {
+	c := NewTradingPairAvgCalculator()
+	c.AddHorizon(window.String(), NewTimeCalculator(window))
+
+	return c
+}
+
+// AddHorizon adds a named window. Every ticker is added to all the horizons.
+func (c *TradingPairAvgCalculator) AddHorizon(name string, calc Averager) {
+	_, decaying := calc.(*DecayCalculator)
+
+	c.horizons = append(c.horizons, horizon{name: name, calc: calc, windowed: !decaying, sinceGap: -1})
+}
+
+// Horizons returns the names of the horizons in the order they were added.
+func (c *TradingPairAvgCalculator) Horizons() []string {
+	names := make([]string, 0, len(c.horizons))
+	for _, h := range c.horizons {
+		names = append(names, h.name)
 	}
+
+	return names
 }
 
-// ProcessHeartBeat updates the lastSequence and last timestamp
-func (c *TradingPairAvgCalculator) ProcessHeartBeat(h entity.HeartBeat) {
-	log.GetLogger().Tracef("heartbeat message processed: %+v", h)
-	c.heartBeatSequence = h.Sequence
+// SetQuoteIncrement sets the quote increment of the product. The average is rounded to a multiple of it.
+func (c *TradingPairAvgCalculator) SetQuoteIncrement(increment entity.Decimal) {
+	c.quoteIncrement = increment
 }
 
-func (c *TradingPairAvgCalculator) ProcessTicker(t entity.Ticker) (avg float64, totalPoints int, err error) {
-	if t.Sequence < c.heartBeatSequence {
-		return 0, 0, fmt.Errorf("%w received sequence: %d last sequence: %d", ErrSequenceNotIncreasing, t.Sequence, c.heartBeatSequence)
+// SetBandMultipliers sets the number of standard deviations of the bands reported around the average (e.g. 1 and 2).
+func (c *TradingPairAvgCalculator) SetBandMultipliers(multipliers ...float64) {
+	c.bandMultipliers = multipliers
+}
+
+// SetPercentiles sets the volume weighted percentiles reported (e.g. 5 and 95) and whether the median is reported.
+// They are reported for the horizons whose averager computes percentiles (see Calculator.EnableOrderStatistics).
+func (c *TradingPairAvgCalculator) SetPercentiles(median bool, percentiles ...float64) {
+	c.median = median
+	c.percentiles = percentiles
+}
+
+// SetWarmUp sets the thresholds a window must meet before its results stop being marked warming up.
+func (c *TradingPairAvgCalculator) SetWarmUp(w WarmUp) {
+	c.warmUp = w
+}
+
+// SetMarkDegraded sets whether the results are marked degraded after a gap in the trades (see MarkGap).
+// A result stays degraded until all the points of its window were received after the gap.
+func (c *TradingPairAvgCalculator) SetMarkDegraded(mark bool) {
+	c.markDegraded = mark
+}
+
+// MarkGap records that trades of the product were lost before the next ticker.
+func (c *TradingPairAvgCalculator) MarkGap() {
+	c.gap = true
+}
+
+// ProcessTicker adds the ticker to every horizon and returns one result per horizon in the order they were added.
+// If the ticker closed the session of a session horizon, the final result of the closed session precedes the result of the horizon.
+func (c *TradingPairAvgCalculator) ProcessTicker(t entity.Ticker) ([]entity.AverageResult, error) {
+	if err := c.checkTicker(t); err != nil {
+		return nil, err
 	}
 
+	gap := c.gap
+	c.gap = false
+
 	newPoint := entity.DataPoint{
-		Value:  t.Price,
-		Volume: t.Volume,
+		Value:     t.Price,
+		Volume:    t.Volume,
+		Side:      t.Side,
+		Timestamp: t.Timestamp,
 	}
 
-	// add the new point to calculator
-	c.calc.Add(newPoint)
+	results := make([]entity.AverageResult, 0, len(c.horizons))
+
+	for i := range c.horizons {
+		h := &c.horizons[i]
+
+		// add the new point to calculator
+		h.calc.Add(newPoint)
 
-	avg, totalPoints = c.calc.ComputeAverage()
+		avg, totalPoints := h.calc.ComputeAverage()
+		stdDev := h.calc.ComputeStdDev()
+		stats := h.calc.ComputeStats()
+
+		result := entity.AverageResult{
+			ProductID:    t.ProductID,
+			Method:       entity.VWAP,
+			Horizon:      h.name,
+			Average:      avg.Round(c.quoteIncrement),
+			ExactAverage: avg,
+			StdDev:       stdDev.Round(c.quoteIncrement),
+			Bands:        c.bands(avg, stdDev),
+			OrderFlow:    c.roundOrderFlow(h.calc.ComputeOrderFlow()),
+			TotalPoints:  totalPoints,
+			Window:       stats,
+			WarmingUp:    !c.warmUp.Met(stats),
+			Degraded:     c.degraded(h, gap, totalPoints),
+		}
+
+		if p, ok := h.calc.(percentileAverager); ok {
+			result.Median, result.Percentiles = c.orderStatistics(p)
+		}
+
+		if s, ok := h.calc.(sessionAverager); ok {
+			if closed, found := s.PopClosedSession(); found {
+				results = append(results, entity.AverageResult{
+					ProductID:    t.ProductID,
+					Method:       entity.VWAP,
+					Horizon:      h.name,
+					Average:      closed.Average.Round(c.quoteIncrement),
+					ExactAverage: closed.Average,
+					StdDev:       closed.StdDev.Round(c.quoteIncrement),
+					Bands:        c.bands(closed.Average, closed.StdDev),
+					OrderFlow:    c.roundOrderFlow(closed.OrderFlow),
+					TotalPoints:  closed.TotalPoints,
+					SessionStart: closed.Start,
+					SessionClose: true,
+				})
+			}
+
+			result.SessionStart = s.SessionStart()
+		}
+
+		results = append(results, result)
+	}
+
+	log.GetLogger().Debugf("new ticker processed: %+v. new averages: %+v", t, results)
+
+	return results, nil
+}
+
+// degraded returns true if the window of the horizon holds points received before the last gap.
+// The windows are FIFO so the window has turned over once it holds no more points than were added since the gap.
+func (c *TradingPairAvgCalculator) degraded(h *horizon, gap bool, totalPoints int) bool {
+	if !c.markDegraded || !h.windowed {
+		return false
+	}
+
+	return windowDegraded(&h.sinceGap, gap, totalPoints)
+}
+
+// windowDegraded counts the point just added to a FIFO window of totalPoints points in sinceGap, the number of points added
+// since the last gap or -1 if the window holds no point older than the gap. It returns true while the window holds points older than the gap.
+func windowDegraded(sinceGap *int, gap bool, totalPoints int) bool {
+	if gap {
+		*sinceGap = 0
+	}
+
+	if *sinceGap < 0 {
+		return false
+	}
+
+	*sinceGap++
+
+	if totalPoints <= *sinceGap {
+		*sinceGap = -1
+
+		return false
+	}
+
+	return true
+}
+
+// orderStatistics returns the median and the percentiles of the averager rounded to the quote increment.
+func (c *TradingPairAvgCalculator) orderStatistics(p percentileAverager) (median entity.Decimal, percentiles []entity.Percentile) {
+	if c.median {
+		median, _ = p.ComputePercentile(50)
+		median = median.Round(c.quoteIncrement)
+	}
+
+	if len(c.percentiles) == 0 {
+		return median, nil
+	}
+
+	percentiles = make([]entity.Percentile, 0, len(c.percentiles))
+
+	for _, pc := range c.percentiles {
+		value, found := p.ComputePercentile(pc)
+		if !found {
+			return median, nil
+		}
+
+		percentiles = append(percentiles, entity.Percentile{Percentile: pc, Value: value.Round(c.quoteIncrement)})
+	}
+
+	return median, percentiles
+}
+
+// bands returns avg ± multiplier * stdDev for each band multiplier.
+func (c *TradingPairAvgCalculator) bands(avg, stdDev entity.Decimal) []entity.Band {
+	if len(c.bandMultipliers) == 0 {
+		return nil
+	}
+
+	bands := make([]entity.Band, 0, len(c.bandMultipliers))
+
+	for _, m := range c.bandMultipliers {
+		width := entity.DecimalFromFloat(m * stdDev.Float64())
+
+		bands = append(bands, entity.Band{
+			Multiplier: m,
+			Upper:      avg.Add(width).Round(c.quoteIncrement),
+			Lower:      avg.Sub(width).Round(c.quoteIncrement),
+		})
+	}
+
+	return bands
+}
 
-	log.GetLogger().Debugf("new ticker processed: %+v. new average: %f", t, avg)
+// roundOrderFlow rounds the buy and sell averages to the quote increment.
+func (c *TradingPairAvgCalculator) roundOrderFlow(flow entity.OrderFlow) entity.OrderFlow {
+	flow.BuyAverage = flow.BuyAverage.Round(c.quoteIncrement)
+	flow.SellAverage = flow.SellAverage.Round(c.quoteIncrement)
 
-	return
+	return flow
 }
@@ -17,24 +17,151 @@ func TestCurrencyAvgCalculator(t *testing.T) {
 		Sequence: 1,
 	})
 
-	avg, totalPoints, err := c.ProcessTicker(entity.Ticker{
+	results, err := c.ProcessTicker(entity.Ticker{
 		Sequence:  1,
-		Price:     1,
-		Volume:    1,
+		ProductID: "BTC-USD",
+		Price:     entity.DecimalFromInt(1),
+		Volume:    entity.DecimalFromInt(1),
 		Timestamp: time.Now(),
 	})
 
 	assert.Nil(t, err, "err should be nil")
-	assert.Equal(t, float64(1), avg, "avg should be 1")
-	assert.Equal(t, 1, totalPoints, "total points should be 1")
+	assert.Len(t, results, 1, "should have one horizon")
+	assert.Equal(t, "BTC-USD", results[0].ProductID, "product id should be BTC-USD")
+	assert.Equal(t, "3", results[0].Horizon, "horizon should be named after its size")
+	assert.Equal(t, entity.DecimalFromInt(1), results[0].Average, "avg should be 1")
+	assert.Equal(t, 1, results[0].TotalPoints, "total points should be 1")
 
-	avg, totalPoints, err = c.ProcessTicker(entity.Ticker{
+	_, err = c.ProcessTicker(entity.Ticker{
 		Sequence:  0,
-		Price:     1,
-		Volume:    1,
+		Price:     entity.DecimalFromInt(1),
+		Volume:    entity.DecimalFromInt(1),
 		Timestamp: time.Now(),
 	})
 
 	assert.NotNil(t, err, "should have a error")
 	assert.ErrorIs(t, err, compute.ErrSequenceNotIncreasing, "should have err seq not increasing")
 }
+
+func TestCurrencyAvgCalculatorQuoteIncrement(t *testing.T) {
+	c := compute.NewAvgCalculator(3)
+	c.SetQuoteIncrement(entity.MustParseDecimal("0.01"))
+
+	_, _ = c.ProcessTicker(entity.Ticker{
+		Price:  entity.MustParseDecimal("1.001"),
+		Volume: entity.DecimalFromInt(1),
+	})
+
+	results, err := c.ProcessTicker(entity.Ticker{
+		Price:  entity.MustParseDecimal("1.01"),
+		Volume: entity.DecimalFromInt(1),
+	})
+
+	// (1.001 + 1.01) / 2 = 1.0055
+	assert.Nil(t, err, "err should be nil")
+	assert.Equal(t, "1.01", results[0].Average.String(), "avg should be rounded to the quote increment")
+	assert.Equal(t, "1.0055", results[0].ExactAverage.String(), "the exact avg should not be rounded")
+}
+
+func TestCurrencyAvgCalculatorHorizons(t *testing.T) {
+	c := compute.NewTradingPairAvgCalculator()
+	c.AddHorizon("2", compute.NewCalculator(2))
+	c.AddHorizon("1m", compute.NewTimeCalculator(time.Minute))
+
+	now := time.Now()
+
+	var (
+		results []entity.AverageResult
+		err     error
+	)
+
+	for i, price := range []int64{1, 2, 3} {
+		results, err = c.ProcessTicker(entity.Ticker{
+			Price:     entity.DecimalFromInt(price),
+			Volume:    entity.DecimalFromInt(1),
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+		})
+		assert.Nil(t, err, "err should be nil")
+	}
+
+	assert.Len(t, results, 2, "should have one result per horizon")
+
+	assert.Equal(t, "2", results[0].Horizon)
+	assert.Equal(t, "2.5", results[0].Average.String(), "avg of the last 2 tickers should be 2.5")
+	assert.Equal(t, 2, results[0].TotalPoints)
+
+	assert.Equal(t, "1m", results[1].Horizon)
+	assert.Equal(t, "2", results[1].Average.String(), "avg of the last minute should be 2")
+	assert.Equal(t, 3, results[1].TotalPoints)
+}
+
+func TestCurrencyAvgCalculatorBands(t *testing.T) {
+	c := compute.NewAvgCalculator(2)
+	c.SetBandMultipliers(1, 2)
+
+	_, _ = c.ProcessTicker(entity.Ticker{Price: entity.DecimalFromInt(10), Volume: entity.DecimalFromInt(1)})
+
+	results, err := c.ProcessTicker(entity.Ticker{Price: entity.DecimalFromInt(20), Volume: entity.DecimalFromInt(1)})
+	assert.Nil(t, err, "err should be nil")
+
+	// avg = 15, stddev = 5
+	r := results[0]
+	assert.Equal(t, "15", r.Average.String())
+	assert.Equal(t, "5", r.StdDev.String())
+	assert.Equal(t, []entity.Band{
+		{Multiplier: 1, Upper: entity.DecimalFromInt(20), Lower: entity.DecimalFromInt(10)},
+		{Multiplier: 2, Upper: entity.DecimalFromInt(25), Lower: entity.DecimalFromInt(5)},
+	}, r.Bands)
+}
+
+func TestCurrencyAvgCalculatorGaps(t *testing.T) {
+	c := compute.NewAvgCalculator(3)
+	c.SetMarkDegraded(true)
+
+	degraded := make([]bool, 0, 8)
+
+	// trades are lost before the 4th and the 5th tickers
+	for i := 0; i < 8; i++ {
+		if i == 3 || i == 4 {
+			c.MarkGap()
+		}
+
+		results, err := c.ProcessTicker(entity.Ticker{
+			Price:  entity.DecimalFromInt(1),
+			Volume: entity.DecimalFromInt(1),
+		})
+
+		assert.Nil(t, err)
+		degraded = append(degraded, results[0].Degraded)
+	}
+
+	// the window of 3 points has turned over 3 points after the last gap
+	assert.Equal(t, []bool{false, false, false, true, true, true, false, false}, degraded)
+}
+
+func TestCurrencyAvgCalculatorWarmUp(t *testing.T) {
+	c := compute.NewTimeAvgCalculator(time.Hour)
+	c.SetWarmUp(compute.WarmUp{
+		MinPoints: 2,
+		MinVolume: entity.DecimalFromInt(3),
+		MinSpan:   time.Minute,
+	})
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+
+	warmingUp := make([]bool, 0, 4)
+
+	for i, volume := range []int64{1, 2, 1, 1} {
+		results, err := c.ProcessTicker(entity.Ticker{
+			Price:     entity.DecimalFromInt(1),
+			Volume:    entity.DecimalFromInt(volume),
+			Timestamp: start.Add(time.Duration(i) * 40 * time.Second),
+		})
+
+		assert.Nil(t, err)
+		warmingUp = append(warmingUp, results[0].WarmingUp)
+	}
+
+	// the second point meets the number of points and the volume but the span is only 40s
+	assert.Equal(t, []bool{true, true, false, false}, warmingUp)
+}
@@ -0,0 +1,213 @@
+package compute
+
+import (
+	"math"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+// priceTree is an order-statistics tree of prices weighted by volume.
+// It is a treap keyed by price where each node holds the volume traded at its price and the volume of its subtree,
+// so adding or removing a point and finding a volume weighted quantile are O(log n) without sorting the window.
+// The nodes of removed prices are kept for reuse so the tree stops allocating once the window is full.
+type priceTree struct {
+	root *priceNode
+	// free -- nodes removed from the tree available for reuse
+	free []*priceNode
+	// seed -- state of the xorshift generator of the priorities
+	seed uint64
+}
+
+type priceNode struct {
+	price int64
+	// count -- number of points at the price. The node is removed when it drops to zero.
+	count int
+	// volume -- volume of the points at the price
+	volume int64
+	// sum -- volume of the subtree
+	sum         int64
+	priority    uint64
+	left, right *priceNode
+}
+
+func newPriceTree() *priceTree {
+	return &priceTree{seed: 0x9e3779b97f4a7c15}
+}
+
+// Add adds the volume of the point at its price.
+func (t *priceTree) Add(p entity.DataPoint) {
+	t.root = t.insert(t.root, p.Value.Units(), p.Volume.Units())
+}
+
+// Remove removes the volume of the point from its price. The point must have been added.
+func (t *priceTree) Remove(p entity.DataPoint) {
+	t.root = t.delete(t.root, p.Value.Units(), p.Volume.Units())
+}
+
+// Quantile returns the lowest price such that the volume of the points at or below it is at least q of the total volume.
+// q goes from 0 to 1. It returns false if the tree has no volume.
+func (t *priceTree) Quantile(q float64) (entity.Decimal, bool) {
+	if t.root == nil || t.root.sum <= 0 {
+		return entity.Decimal{}, false
+	}
+
+	target := int64(math.Ceil(q * float64(t.root.sum)))
+	if target < 1 {
+		target = 1
+	}
+
+	n := t.root
+
+	for {
+		left := n.left.total()
+		if target <= left {
+			n = n.left
+
+			continue
+		}
+
+		target -= left
+
+		if target <= n.volume || n.right == nil {
+			return entity.NewDecimal(n.price), true
+		}
+
+		target -= n.volume
+		n = n.right
+	}
+}
+
+func (t *priceTree) insert(n *priceNode, price, volume int64) *priceNode {
+	if n == nil {
+		return t.newNode(price, volume)
+	}
+
+	switch {
+	case price < n.price:
+		n.left = t.insert(n.left, price, volume)
+		if n.left.priority > n.priority {
+			n = rotateRight(n)
+		}
+	case price > n.price:
+		n.right = t.insert(n.right, price, volume)
+		if n.right.priority > n.priority {
+			n = rotateLeft(n)
+		}
+	default:
+		n.count++
+		n.volume += volume
+	}
+
+	n.update()
+
+	return n
+}
+
+func (t *priceTree) delete(n *priceNode, price, volume int64) *priceNode {
+	if n == nil {
+		return nil
+	}
+
+	switch {
+	case price < n.price:
+		n.left = t.delete(n.left, price, volume)
+	case price > n.price:
+		n.right = t.delete(n.right, price, volume)
+	default:
+		n.count--
+		n.volume -= volume
+
+		if n.count <= 0 {
+			return t.deleteNode(n)
+		}
+	}
+
+	n.update()
+
+	return n
+}
+
+// deleteNode removes n from its subtree by rotating it down to a leaf and returns the new root of the subtree.
+func (t *priceTree) deleteNode(n *priceNode) *priceNode {
+	switch {
+	case n.left == nil:
+		right := n.right
+		t.release(n)
+
+		return right
+	case n.right == nil:
+		left := n.left
+		t.release(n)
+
+		return left
+	case n.left.priority > n.right.priority:
+		root := rotateRight(n)
+		root.right = t.deleteNode(n)
+		root.update()
+
+		return root
+	default:
+		root := rotateLeft(n)
+		root.left = t.deleteNode(n)
+		root.update()
+
+		return root
+	}
+}
+
+func (t *priceTree) newNode(price, volume int64) *priceNode {
+	var n *priceNode
+
+	if len(t.free) > 0 {
+		n = t.free[len(t.free)-1]
+		t.free = t.free[:len(t.free)-1]
+	} else {
+		n = &priceNode{}
+	}
+
+	// xorshift64
+	t.seed ^= t.seed << 13
+	t.seed ^= t.seed >> 7
+	t.seed ^= t.seed << 17
+
+	*n = priceNode{price: price, count: 1, volume: volume, sum: volume, priority: t.seed}
+
+	return n
+}
+
+func (t *priceTree) release(n *priceNode) {
+	*n = priceNode{}
+	t.free = append(t.free, n)
+}
+
+func (n *priceNode) total() int64 {
+	if n == nil {
+		return 0
+	}
+
+	return n.sum
+}
+
+func (n *priceNode) update() {
+	n.sum = n.left.total() + n.volume + n.right.total()
+}
+
+func rotateRight(n *priceNode) *priceNode {
+	l := n.left
+	n.left = l.right
+	n.update()
+	l.right = n
+	l.update()
+
+	return l
+}
+
+func rotateLeft(n *priceNode) *priceNode {
+	r := n.right
+	n.right = r.left
+	n.update()
+	r.left = n
+	r.update()
+
+	return r
+}
@@ -0,0 +1,104 @@
+package compute
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestPriceTree(t *testing.T) {
+	tree := newPriceTree()
+
+	_, found := tree.Quantile(0.5)
+	assert.False(t, found, "empty tree has no quantile")
+
+	for _, p := range []entity.DataPoint{
+		{Value: entity.DecimalFromInt(10), Volume: entity.DecimalFromInt(1)},
+		{Value: entity.DecimalFromInt(30), Volume: entity.DecimalFromInt(3)},
+		{Value: entity.DecimalFromInt(20), Volume: entity.DecimalFromInt(1)},
+		{Value: entity.DecimalFromInt(30), Volume: entity.DecimalFromInt(1)},
+	} {
+		tree.Add(p)
+	}
+
+	// cumulative volume: 10 -> 1, 20 -> 2, 30 -> 6
+	median, _ := tree.Quantile(0.5)
+	assert.Equal(t, entity.DecimalFromInt(30), median)
+
+	p5, _ := tree.Quantile(0.05)
+	assert.Equal(t, entity.DecimalFromInt(10), p5)
+
+	p30, _ := tree.Quantile(0.3)
+	assert.Equal(t, entity.DecimalFromInt(20), p30)
+
+	tree.Remove(entity.DataPoint{Value: entity.DecimalFromInt(30), Volume: entity.DecimalFromInt(3)})
+	tree.Remove(entity.DataPoint{Value: entity.DecimalFromInt(30), Volume: entity.DecimalFromInt(1)})
+
+	// 30 is gone
+	p100, _ := tree.Quantile(1)
+	assert.Equal(t, entity.DecimalFromInt(20), p100)
+}
+
+// TestPriceTreeRandom compares the tree with a sorted copy of a sliding window.
+func TestPriceTreeRandom(t *testing.T) {
+	const size = 50
+
+	rnd := rand.New(rand.NewSource(1))
+	tree := newPriceTree()
+	window := make([]entity.DataPoint, 0, size+1)
+
+	for i := 0; i < 5000; i++ {
+		p := entity.DataPoint{
+			Value:  entity.NewDecimal(rnd.Int63n(100)),
+			Volume: entity.NewDecimal(rnd.Int63n(10)),
+		}
+
+		tree.Add(p)
+		window = append(window, p)
+
+		if len(window) > size {
+			tree.Remove(window[0])
+			window = window[1:]
+		}
+
+		for _, q := range []float64{0, 0.05, 0.5, 0.95, 1} {
+			expected, expectedFound := bruteForceQuantile(window, q)
+			actual, found := tree.Quantile(q)
+
+			assert.Equal(t, expectedFound, found)
+			assert.Equal(t, expected, actual, "quantile %g at %d", q, i)
+		}
+	}
+}
+
+func bruteForceQuantile(points []entity.DataPoint, q float64) (entity.Decimal, bool) {
+	sorted := append([]entity.DataPoint(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Value.Cmp(sorted[j].Value) < 0 })
+
+	var total int64
+	for _, p := range sorted {
+		total += p.Volume.Units()
+	}
+
+	if total == 0 {
+		return entity.Decimal{}, false
+	}
+
+	target := int64(math.Max(1, math.Ceil(q*float64(total))))
+
+	var cumulative int64
+
+	for _, p := range sorted {
+		cumulative += p.Volume.Units()
+		if cumulative >= target {
+			return p.Value, true
+		}
+	}
+
+	return sorted[len(sorted)-1].Value, true
+}
@@ -0,0 +1,107 @@
+package compute
+
+import (
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+// ring is a FIFO queue of points backed by a preallocated circular buffer.
+// Push and Pop are O(1) and do not allocate as long as the ring is not full.
+// If a point is pushed into a full ring, the buffer is doubled.
+type ring struct {
+	points []entity.DataPoint
+	// head is the index of the oldest point
+	head int
+	size int
+}
+
+func newRing(capacity int) *ring {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &ring{
+		points: make([]entity.DataPoint, capacity),
+	}
+}
+
+// Push adds p after the newest point.
+func (r *ring) Push(p entity.DataPoint) {
+	if r.size == len(r.points) {
+		r.grow()
+	}
+
+	r.points[(r.head+r.size)%len(r.points)] = p
+	r.size++
+}
+
+// Insert adds p at position i from the oldest point, shifting the newer points. i must be at most the size of the ring.
+// It is O(size - i).
+func (r *ring) Insert(i int, p entity.DataPoint) {
+	r.Push(p)
+
+	for j := r.size - 1; j > i; j-- {
+		r.set(j, r.at(j-1))
+	}
+
+	r.set(i, p)
+}
+
+// Pop removes and returns the oldest point. It returns false if the ring is empty.
+func (r *ring) Pop() (entity.DataPoint, bool) {
+	if r.size == 0 {
+		return entity.DataPoint{}, false
+	}
+
+	p := r.points[r.head]
+	r.points[r.head] = entity.DataPoint{}
+	r.head = (r.head + 1) % len(r.points)
+	r.size--
+
+	return p, true
+}
+
+// Peek returns the oldest point without removing it. It returns false if the ring is empty.
+func (r *ring) Peek() (entity.DataPoint, bool) {
+	if r.size == 0 {
+		return entity.DataPoint{}, false
+	}
+
+	return r.points[r.head], true
+}
+
+// ReplaceOldest replaces the oldest point by p. The ring must not be empty.
+func (r *ring) ReplaceOldest(p entity.DataPoint) {
+	r.points[r.head] = p
+}
+
+func (r *ring) Size() int {
+	return r.size
+}
+
+// grow doubles the capacity of the ring keeping the order of the points.
+func (r *ring) grow() {
+	points := make([]entity.DataPoint, 2*len(r.points))
+
+	n := copy(points, r.points[r.head:])
+	copy(points[n:], r.points[:r.head])
+
+	r.points = points
+	r.head = 0
+}
+
+// Do calls f for each point from the oldest to the newest.
+func (r *ring) Do(f func(p entity.DataPoint)) {
+	for i := 0; i < r.size; i++ {
+		f(r.at(i))
+	}
+}
+
+// at returns the i-th point from the oldest one. i must be lower than the size of the ring.
+func (r *ring) at(i int) entity.DataPoint {
+	return r.points[(r.head+i)%len(r.points)]
+}
+
+// set replaces the i-th point from the oldest one. i must be lower than the size of the ring.
+func (r *ring) set(i int, p entity.DataPoint) {
+	r.points[(r.head+i)%len(r.points)] = p
+}
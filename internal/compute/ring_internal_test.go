@@ -0,0 +1,102 @@
+package compute
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestRing(t *testing.T) {
+	r := newRing(2)
+
+	r.Push(entity.DataPoint{Value: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1)})
+	r.Push(entity.DataPoint{Value: entity.DecimalFromInt(2), Volume: entity.DecimalFromInt(2)})
+
+	assert.Equal(t, 2, r.Size(), "expected 2 elements in the ring")
+
+	p, ok := r.Pop()
+	assert.True(t, ok, "expect a point")
+	assert.Equal(t, entity.DecimalFromInt(1), p.Value, "expect 1 as value")
+	assert.Equal(t, 1, r.Size(), "expect size 1")
+
+	// wrap around the end of the buffer
+	r.Push(entity.DataPoint{Value: entity.DecimalFromInt(3), Volume: entity.DecimalFromInt(3)})
+
+	p, ok = r.Peek()
+	assert.True(t, ok, "expect a point")
+	assert.Equal(t, entity.DecimalFromInt(2), p.Value, "expect 2 as value")
+	assert.Equal(t, 2, r.Size(), "expect size 2")
+
+	p, _ = r.Pop()
+	assert.Equal(t, entity.DecimalFromInt(2), p.Value, "expect 2 as value")
+
+	p, _ = r.Pop()
+	assert.Equal(t, entity.DecimalFromInt(3), p.Value, "expect 3 as value")
+	assert.Equal(t, 0, r.Size(), "expect size 0")
+
+	// pop again we should get nothing
+	_, ok = r.Pop()
+	assert.False(t, ok, "expect no point")
+}
+
+func TestRingGrow(t *testing.T) {
+	r := newRing(2)
+
+	r.Push(entity.DataPoint{Value: entity.DecimalFromInt(1)})
+	r.Push(entity.DataPoint{Value: entity.DecimalFromInt(2)})
+	r.Pop()
+	r.Push(entity.DataPoint{Value: entity.DecimalFromInt(3)})
+
+	// the ring is full and wrapped. the order must be kept after growing.
+	r.Push(entity.DataPoint{Value: entity.DecimalFromInt(4)})
+
+	assert.Equal(t, 3, r.Size(), "expect size 3")
+
+	for _, expected := range []int64{2, 3, 4} {
+		p, _ := r.Pop()
+		assert.Equal(t, entity.DecimalFromInt(expected), p.Value)
+	}
+}
+
+func TestRingInsert(t *testing.T) {
+	r := newRing(3)
+
+	r.Push(entity.DataPoint{Value: entity.DecimalFromInt(0)})
+	r.Pop()
+	r.Push(entity.DataPoint{Value: entity.DecimalFromInt(1)})
+	r.Push(entity.DataPoint{Value: entity.DecimalFromInt(3)})
+
+	// the ring is wrapped and grows
+	r.Insert(1, entity.DataPoint{Value: entity.DecimalFromInt(2)})
+	r.Insert(0, entity.DataPoint{Value: entity.DecimalFromInt(0)})
+	r.Insert(4, entity.DataPoint{Value: entity.DecimalFromInt(4)})
+
+	assert.Equal(t, 5, r.Size(), "expect size 5")
+
+	for _, expected := range []int64{0, 1, 2, 3, 4} {
+		p, _ := r.Pop()
+		assert.Equal(t, entity.DecimalFromInt(expected), p.Value)
+	}
+}
+
+func BenchmarkRingPushPop(b *testing.B) {
+	for _, size := range []int{200, 100_000, 10_000_000} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			r := newRing(size)
+			for i := 0; i < size; i++ {
+				r.Push(entity.DataPoint{Value: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1)})
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				p, _ := r.Pop()
+				r.Push(p)
+			}
+		})
+	}
+}
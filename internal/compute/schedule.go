@@ -0,0 +1,206 @@
+package compute
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule means that the schedule cannot be parsed.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// scheduleMacros are the shortcuts accepted in place of the five fields.
+var scheduleMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// maxScheduleSearch limits the search of the next or previous time of a schedule.
+// It is long enough to reach a leap day.
+const maxScheduleSearch = 5 * 366 * 24 * time.Hour
+
+// Schedule is a cron-like schedule evaluated in UTC with a precision of one minute.
+// The spec has five fields "minute hour day-of-month month day-of-week". Each field accepts
+// "*", numbers, ranges "a-b", lists "a,b" and steps "*/n" or "a-b/n". Day of week goes from 0 (sunday) to 7 (sunday).
+// As in cron, if both day of month and day of week are restricted, a day matches if either of them matches.
+type Schedule struct {
+	spec    string
+	minutes uint64
+	hours   uint64
+	days    uint64
+	months  uint64
+	weekday uint64
+	// anyDay is true if either the day of month or the day of week is "*"
+	anyDay bool
+}
+
+// ParseSchedule parses a cron-like spec like "30 14 * * 1-5" or a macro like "@daily".
+func ParseSchedule(spec string) (*Schedule, error) {
+	expanded := spec
+	if macro, found := scheduleMacros[strings.TrimSpace(spec)]; found {
+		expanded = macro
+	}
+
+	fields := strings.Fields(expanded)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w %q: expected 5 fields", ErrInvalidSchedule, spec)
+	}
+
+	s := &Schedule{spec: spec}
+
+	limits := []struct {
+		field    *uint64
+		min, max int
+	}{
+		{&s.minutes, 0, 59},
+		{&s.hours, 0, 23},
+		{&s.days, 1, 31},
+		{&s.months, 1, 12},
+		{&s.weekday, 0, 7},
+	}
+
+	for i, l := range limits {
+		bits, err := parseScheduleField(fields[i], l.min, l.max)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidSchedule, spec, err)
+		}
+
+		*l.field = bits
+	}
+
+	// 7 is sunday as well
+	if s.weekday&(1<<7) != 0 {
+		s.weekday |= 1
+	}
+
+	s.anyDay = fields[2] == "*" || fields[4] == "*"
+
+	if s.Next(time.Unix(0, 0)).IsZero() {
+		return nil, fmt.Errorf("%w %q: never happens", ErrInvalidSchedule, spec)
+	}
+
+	return s, nil
+}
+
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first time of the schedule strictly after t. It returns the zero time if there is none.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+
+	for limit := t.Add(maxScheduleSearch); t.Before(limit); {
+		switch {
+		case s.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hours&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// Prev returns the last time of the schedule before or equal to t. It returns the zero time if there is none.
+func (s *Schedule) Prev(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute)
+
+	for limit := t.Add(-maxScheduleSearch); t.After(limit); {
+		switch {
+		case s.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Add(-time.Minute)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Add(-time.Minute)
+		case s.hours&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(-time.Minute)
+		case s.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(-time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) matchDay(t time.Time) bool {
+	dayMatch := s.days&(1<<uint(t.Day())) != 0
+	weekdayMatch := s.weekday&(1<<uint(t.Weekday())) != 0
+
+	if s.anyDay {
+		return dayMatch && weekdayMatch
+	}
+
+	return dayMatch || weekdayMatch
+}
+
+// parseScheduleField returns the bit set of the values matched by the field.
+func parseScheduleField(field string, min, max int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+
+			rangePart, step = part[:i], n
+		}
+
+		low, high, err := parseScheduleRange(rangePart, min, max)
+		if err != nil {
+			return 0, err
+		}
+
+		// "a/n" means from a to max every n
+		if step > 1 && !strings.ContainsAny(rangePart, "*-") {
+			high = max
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseScheduleRange(r string, min, max int) (low, high int, err error) {
+	if r == "*" {
+		return min, max, nil
+	}
+
+	lowStr, highStr := r, r
+	if i := strings.IndexByte(r, '-'); i >= 0 {
+		lowStr, highStr = r[:i], r[i+1:]
+	}
+
+	if low, err = strconv.Atoi(lowStr); err != nil {
+		return 0, 0, fmt.Errorf("invalid value %q", r)
+	}
+
+	if high, err = strconv.Atoi(highStr); err != nil {
+		return 0, 0, fmt.Errorf("invalid value %q", r)
+	}
+
+	if low < min || high > max || low > high {
+		return 0, 0, fmt.Errorf("value %q out of range [%d-%d]", r, min, max)
+	}
+
+	return low, high, nil
+}
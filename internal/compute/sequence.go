@@ -0,0 +1,30 @@
+package compute
+
+import (
+	"fmt"
+
+	"github.com/tupyy/vwap/internal/entity"
+	"github.com/tupyy/vwap/internal/log"
+)
+
+// sequenceGuard rejects the tickers whose sequence is lower than the sequence of the last heartbeat.
+// It is embedded by the pair calculators. The gaps in the trades are detected once per product by the manager.
+type sequenceGuard struct {
+	// heartBeatSequence -- holds the last received sequence
+	heartBeatSequence int64
+}
+
+// ProcessHeartBeat updates the last sequence.
+func (g *sequenceGuard) ProcessHeartBeat(h entity.HeartBeat) {
+	log.GetLogger().Tracef("heartbeat message processed: %+v", h)
+	g.heartBeatSequence = h.Sequence
+}
+
+// checkTicker returns ErrSequenceNotIncreasing if the ticker arrived after a heartbeat with a greater sequence.
+func (g *sequenceGuard) checkTicker(t entity.Ticker) error {
+	if t.Sequence < g.heartBeatSequence {
+		return fmt.Errorf("%w received sequence: %d last sequence: %d", ErrSequenceNotIncreasing, t.Sequence, g.heartBeatSequence)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package compute
+
+import (
+	"time"
+
+	"github.com/tupyy/vwap/internal/entity"
+	"github.com/tupyy/vwap/internal/log"
+)
+
+// Session holds the average of a closed session.
+type Session struct {
+	// Start -- anchor which opened the session
+	Start time.Time
+	// End -- anchor which closed the session
+	End time.Time
+	// Average -- average over all the points of the session
+	Average entity.Decimal
+	// StdDev -- volume weighted standard deviation of the session
+	StdDev entity.Decimal
+	// OrderFlow -- averages and volumes of the buy and sell points of the session
+	OrderFlow entity.OrderFlow
+	// TotalPoints -- number of points of the session
+	TotalPoints int
+}
+
+// SessionCalculator computes the volume weighted average of all the points since the last anchor of its schedule.
+// Unlike Calculator, the points are never evicted: the sums are reset when a point falls after the next anchor.
+// Sessions roll over on the timestamp of the points so a session is closed by the first point of the next one.
+type SessionCalculator struct {
+	schedule *Schedule
+	// start is the anchor of the current session
+	start time.Time
+	// end is the next anchor which closes the current session
+	end time.Time
+	// sums holds the running sums of all points of the session
+	sums vwapSums
+	// flow holds the running sums of the buy and sell points of the session
+	flow flowSums
+	// totalPoints is the number of points of the session
+	totalPoints int
+	// first and last are the timestamps of the oldest and newest points of the session
+	first, last time.Time
+	// closed holds the last closed session until it is popped
+	closed *Session
+}
+
+func NewSessionCalculator(schedule *Schedule) *SessionCalculator {
+	return &SessionCalculator{
+		schedule: schedule,
+	}
+}
+
+func (c *SessionCalculator) Add(p entity.DataPoint) {
+	if !p.Timestamp.Before(c.end) {
+		c.rollOver(p.Timestamp)
+	}
+
+	// the point belongs to a closed session
+	if p.Timestamp.Before(c.start) {
+		log.GetLogger().Debugf("point %+v before the start of the session %s. ignored", p, c.start)
+
+		return
+	}
+
+	if c.totalPoints == 0 || p.Timestamp.Before(c.first) {
+		c.first = p.Timestamp
+	}
+
+	if p.Timestamp.After(c.last) {
+		c.last = p.Timestamp
+	}
+
+	c.sums.Add(p)
+	c.flow.Add(p)
+	c.totalPoints++
+}
+
+// ComputeAverage returns the average of the current session. It returns 0 if the session has no volume.
+func (c *SessionCalculator) ComputeAverage() (avg entity.Decimal, totalPoints int) {
+	return c.sums.Average(), c.totalPoints
+}
+
+// ComputeStdDev returns the volume weighted standard deviation of the current session. It returns 0 if the session has no volume.
+func (c *SessionCalculator) ComputeStdDev() entity.Decimal {
+	return c.sums.StdDev()
+}
+
+// ComputeOrderFlow returns the averages and volumes of the buy and sell points of the current session.
+func (c *SessionCalculator) ComputeOrderFlow() entity.OrderFlow {
+	return c.flow.OrderFlow()
+}
+
+// ComputeStats returns the number of points, the total volume and the timestamps of the oldest and newest points of the current session.
+func (c *SessionCalculator) ComputeStats() entity.WindowStats {
+	if c.totalPoints == 0 {
+		return entity.WindowStats{}
+	}
+
+	return entity.WindowStats{
+		TotalPoints: c.totalPoints,
+		Volume:      c.sums.totalVolume,
+		Start:       c.first,
+		End:         c.last,
+	}
+}
+
+// SessionStart returns the anchor of the current session.
+func (c *SessionCalculator) SessionStart() time.Time {
+	return c.start
+}
+
+// PopClosedSession returns the session closed by the last added point, if any.
+func (c *SessionCalculator) PopClosedSession() (Session, bool) {
+	if c.closed == nil {
+		return Session{}, false
+	}
+
+	closed := *c.closed
+	c.closed = nil
+
+	return closed, true
+}
+
+// rollOver closes the current session, if it has points, and opens the session containing t.
+func (c *SessionCalculator) rollOver(t time.Time) {
+	if c.totalPoints > 0 {
+		avg, totalPoints := c.ComputeAverage()
+
+		c.closed = &Session{
+			Start:       c.start,
+			End:         c.end,
+			Average:     avg,
+			StdDev:      c.ComputeStdDev(),
+			OrderFlow:   c.ComputeOrderFlow(),
+			TotalPoints: totalPoints,
+		}
+
+		log.GetLogger().Infof("session %s closed: %+v", c.schedule, *c.closed)
+	}
+
+	c.start = c.schedule.Prev(t)
+	c.end = c.schedule.Next(t)
+	c.sums = vwapSums{}
+	c.flow = flowSums{}
+	c.totalPoints = 0
+	c.first = time.Time{}
+	c.last = time.Time{}
+}
@@ -0,0 +1,124 @@
+package compute_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/compute"
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestSchedule(t *testing.T) {
+	// 2021-11-12 is a friday
+	now := time.Date(2021, 11, 12, 10, 15, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		prev time.Time
+		next time.Time
+	}{
+		{
+			spec: "@daily",
+			prev: time.Date(2021, 11, 12, 0, 0, 0, 0, time.UTC),
+			next: time.Date(2021, 11, 13, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "*/20 * * * *",
+			prev: time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC),
+			next: time.Date(2021, 11, 12, 10, 20, 0, 0, time.UTC),
+		},
+		{
+			// weekdays session open at 14:30
+			spec: "30 14 * * 1-5",
+			prev: time.Date(2021, 11, 11, 14, 30, 0, 0, time.UTC),
+			next: time.Date(2021, 11, 12, 14, 30, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 0 1 1,7 *",
+			prev: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC),
+			next: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		s, err := compute.ParseSchedule(test.spec)
+		assert.Nil(t, err, "spec: %s", test.spec)
+		assert.Equal(t, test.prev, s.Prev(now), "spec: %s", test.spec)
+		assert.Equal(t, test.next, s.Next(now), "spec: %s", test.spec)
+	}
+
+	for _, spec := range []string{"* * *", "60 * * * *", "0 0 31 2 *", "*/0 * * * *", "a * * * *"} {
+		_, err := compute.ParseSchedule(spec)
+		assert.ErrorIs(t, err, compute.ErrInvalidSchedule, "spec: %s", spec)
+	}
+}
+
+func TestSessionCalculator(t *testing.T) {
+	schedule, err := compute.ParseSchedule("@daily")
+	assert.Nil(t, err)
+
+	calc := compute.NewSessionCalculator(schedule)
+
+	day := time.Date(2021, 11, 12, 0, 0, 0, 0, time.UTC)
+
+	calc.Add(timedPoint("1", "1", day.Add(time.Hour)))
+	calc.Add(timedPoint("2", "2", day.Add(23*time.Hour)))
+
+	avg, totalPoints := calc.ComputeAverage()
+	assert.Equal(t, "1.66666667", avg.String(), "expect avg = 1.6667")
+	assert.Equal(t, 2, totalPoints, "expect 2 computation points")
+	assert.Equal(t, day, calc.SessionStart(), "session should start at midnight")
+
+	_, found := calc.PopClosedSession()
+	assert.False(t, found, "no session should be closed")
+
+	// the first point of the next day closes the session
+	calc.Add(timedPoint("4", "1", day.Add(25*time.Hour)))
+
+	avg, totalPoints = calc.ComputeAverage()
+	assert.Equal(t, "4", avg.String(), "expect avg = 4")
+	assert.Equal(t, 1, totalPoints, "expect 1 computation point")
+	assert.Equal(t, day.Add(24*time.Hour), calc.SessionStart(), "session should start at the next midnight")
+
+	closed, found := calc.PopClosedSession()
+	assert.True(t, found, "the session should be closed")
+	assert.Equal(t, day, closed.Start)
+	assert.Equal(t, day.Add(24*time.Hour), closed.End)
+	assert.Equal(t, entity.MustParseDecimal("1.66666667"), closed.Average)
+	assert.Equal(t, 2, closed.TotalPoints)
+
+	_, found = calc.PopClosedSession()
+	assert.False(t, found, "the closed session is popped only once")
+
+	// a late point of the closed session is ignored
+	calc.Add(timedPoint("100", "1", day.Add(23*time.Hour)))
+
+	_, totalPoints = calc.ComputeAverage()
+	assert.Equal(t, 1, totalPoints, "expect 1 computation point")
+}
+
+func TestCurrencyAvgCalculatorSessionClose(t *testing.T) {
+	schedule, _ := compute.ParseSchedule("@daily")
+
+	c := compute.NewTradingPairAvgCalculator()
+	c.AddHorizon("daily", compute.NewSessionCalculator(schedule))
+
+	day := time.Date(2021, 11, 12, 0, 0, 0, 0, time.UTC)
+
+	results, _ := c.ProcessTicker(entity.Ticker{Price: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1), Timestamp: day.Add(time.Hour)})
+	assert.Len(t, results, 1, "expect one result")
+	assert.Equal(t, day, results[0].SessionStart)
+
+	results, _ = c.ProcessTicker(entity.Ticker{Price: entity.DecimalFromInt(3), Volume: entity.DecimalFromInt(1), Timestamp: day.Add(25 * time.Hour)})
+	assert.Len(t, results, 2, "expect the session close and the new session")
+
+	assert.True(t, results[0].SessionClose, "first result should close the session")
+	assert.Equal(t, day, results[0].SessionStart)
+	assert.Equal(t, "1", results[0].Average.String())
+
+	assert.False(t, results[1].SessionClose)
+	assert.Equal(t, day.Add(24*time.Hour), results[1].SessionStart)
+	assert.Equal(t, "3", results[1].Average.String())
+}
@@ -0,0 +1,543 @@
+package compute
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tupyy/vwap/internal/entity"
+	"github.com/tupyy/vwap/internal/log"
+)
+
+// ErrSnapshotMismatch is returned when a snapshot is restored into a calculator configured differently.
+var ErrSnapshotMismatch = errors.New("snapshot does not match the calculator")
+
+// snapshotter is implemented by the averagers whose state can be saved and restored.
+// prepareRestore decodes and checks the state without modifying the averager. It returns the function which restores it.
+// Restore is prepareRestore followed by the restore so a state which cannot be restored leaves the averager untouched.
+type snapshotter interface {
+	Snapshot() (json.RawMessage, error)
+	Restore(state json.RawMessage) error
+	prepareRestore(state json.RawMessage) (func(), error)
+}
+
+// restore restores the state into s. Nothing is changed if the state cannot be restored.
+func restore(s snapshotter, state json.RawMessage) error {
+	apply, err := s.prepareRestore(state)
+	if err != nil {
+		return err
+	}
+
+	apply()
+
+	return nil
+}
+
+// checkKind returns ErrSnapshotMismatch if the state was saved from an averager of another kind.
+func checkKind(saved, kind string) error {
+	if saved != kind {
+		return fmt.Errorf("%w: %q restored into %q", ErrSnapshotMismatch, saved, kind)
+	}
+
+	return nil
+}
+
+// sumsState is the serialized form of vwapSums.
+type sumsState struct {
+	TotalVolume entity.Decimal `json:"total_volume"`
+	// ValueVolumeHi and ValueVolumeLo are the 128 bits of Sum(value * volume)
+	ValueVolumeHi uint64 `json:"value_volume_hi"`
+	ValueVolumeLo uint64 `json:"value_volume_lo"`
+	// ValueSquareVolumeHi, ValueSquareVolumeMid and ValueSquareVolumeLo are the 192 bits of Sum(value^2 * volume)
+	ValueSquareVolumeHi  uint64 `json:"value_square_volume_hi"`
+	ValueSquareVolumeMid uint64 `json:"value_square_volume_mid"`
+	ValueSquareVolumeLo  uint64 `json:"value_square_volume_lo"`
+}
+
+func (s vwapSums) state() sumsState {
+	return sumsState{
+		TotalVolume:          s.totalVolume,
+		ValueVolumeHi:        s.valueVolumeSum.hi,
+		ValueVolumeLo:        s.valueVolumeSum.lo,
+		ValueSquareVolumeHi:  s.valueSquareVolumeSum.hi,
+		ValueSquareVolumeMid: s.valueSquareVolumeSum.mid,
+		ValueSquareVolumeLo:  s.valueSquareVolumeSum.lo,
+	}
+}
+
+func sumsFromState(s sumsState) vwapSums {
+	return vwapSums{
+		totalVolume:          s.TotalVolume,
+		valueVolumeSum:       uint128{s.ValueVolumeHi, s.ValueVolumeLo},
+		valueSquareVolumeSum: uint192{s.ValueSquareVolumeHi, s.ValueSquareVolumeMid, s.ValueSquareVolumeLo},
+	}
+}
+
+// sequenceState is the serialized form of sequenceGuard.
+type sequenceState struct {
+	HeartBeatSequence int64 `json:"heartbeat_sequence"`
+}
+
+func (g *sequenceGuard) state() sequenceState {
+	return sequenceState{
+		HeartBeatSequence: g.heartBeatSequence,
+	}
+}
+
+func (g *sequenceGuard) restore(s sequenceState) {
+	g.heartBeatSequence = s.HeartBeatSequence
+}
+
+type calculatorState struct {
+	// Kind -- kind of the window: a window of another kind cannot be restored from the points
+	Kind string `json:"kind"`
+	// Points -- content of the window, oldest first
+	Points        []entity.DataPoint `json:"points"`
+	LastTimestamp time.Time          `json:"last_timestamp"`
+}
+
+// Snapshot returns the content of the window.
+func (c *Calculator) Snapshot() (json.RawMessage, error) {
+	s := calculatorState{
+		Kind:          c.kind(),
+		Points:        make([]entity.DataPoint, 0, c.window.Size()),
+		LastTimestamp: c.lastTimestamp,
+	}
+
+	c.window.Do(func(p entity.DataPoint) {
+		s.Points = append(s.Points, p)
+	})
+
+	return json.Marshal(s)
+}
+
+// Restore replaces the window with the points of the snapshot. The sums are exact so they are recomputed from the points.
+// The points are added again so a window smaller than the saved one keeps only its newest points.
+// It returns ErrSnapshotMismatch if the snapshot was taken from a window of another kind (count, time or volume).
+func (c *Calculator) Restore(state json.RawMessage) error {
+	return restore(c, state)
+}
+
+func (c *Calculator) prepareRestore(state json.RawMessage) (func(), error) {
+	var s calculatorState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return nil, err
+	}
+
+	if err := checkKind(s.Kind, c.kind()); err != nil {
+		return nil, err
+	}
+
+	return func() { c.restore(s) }, nil
+}
+
+func (c *Calculator) restore(s calculatorState) {
+	for c.window.Size() > 0 {
+		c.window.Pop()
+	}
+
+	c.sums = vwapSums{}
+	c.flow = flowSums{}
+	c.venues = nil
+	c.lastTimestamp = time.Time{}
+	c.addedSinceCheck = 0
+
+	if c.prices != nil {
+		c.prices = newPriceTree()
+	}
+
+	for _, p := range s.Points {
+		c.Add(p)
+	}
+
+	if s.LastTimestamp.After(c.lastTimestamp) {
+		c.lastTimestamp = s.LastTimestamp
+	}
+}
+
+// kind returns the kind of the window. The size of the window is not part of it so a resized window can be restored.
+func (c *Calculator) kind() string {
+	switch {
+	case c.maxAge > 0:
+		return "time"
+	case !c.maxVolume.IsZero():
+		return "volume"
+	default:
+		return "count"
+	}
+}
+
+type sessionState struct {
+	// Kind -- schedule of the sessions
+	Kind        string    `json:"kind"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Sums        sumsState `json:"sums"`
+	BuySums     sumsState `json:"buy_sums"`
+	SellSums    sumsState `json:"sell_sums"`
+	TotalPoints int       `json:"total_points"`
+	First       time.Time `json:"first"`
+	Last        time.Time `json:"last"`
+}
+
+// Snapshot returns the sums of the current session. The points of a session are not stored so the sums are saved as they are.
+func (c *SessionCalculator) Snapshot() (json.RawMessage, error) {
+	return json.Marshal(sessionState{
+		Kind:        c.kind(),
+		Start:       c.start,
+		End:         c.end,
+		Sums:        c.sums.state(),
+		BuySums:     c.flow.buy.state(),
+		SellSums:    c.flow.sell.state(),
+		TotalPoints: c.totalPoints,
+		First:       c.first,
+		Last:        c.last,
+	})
+}
+
+// Restore replaces the current session with the session of the snapshot.
+// A session which closed in the meantime is closed by the next point.
+// It returns ErrSnapshotMismatch if the snapshot was taken with another schedule.
+func (c *SessionCalculator) Restore(state json.RawMessage) error {
+	return restore(c, state)
+}
+
+func (c *SessionCalculator) prepareRestore(state json.RawMessage) (func(), error) {
+	var s sessionState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return nil, err
+	}
+
+	if err := checkKind(s.Kind, c.kind()); err != nil {
+		return nil, err
+	}
+
+	return func() { c.restore(s) }, nil
+}
+
+func (c *SessionCalculator) restore(s sessionState) {
+	c.start = s.Start
+	c.end = s.End
+	c.sums = sumsFromState(s.Sums)
+	c.flow = flowSums{buy: sumsFromState(s.BuySums), sell: sumsFromState(s.SellSums)}
+	c.totalPoints = s.TotalPoints
+	c.first = s.First
+	c.last = s.Last
+	c.closed = nil
+}
+
+func (c *SessionCalculator) kind() string {
+	return "session " + c.schedule.String()
+}
+
+type decayState struct {
+	Kind                string    `json:"kind"`
+	LastTimestamp       time.Time `json:"last_timestamp"`
+	FirstTimestamp      time.Time `json:"first_timestamp"`
+	WeightSum           float64   `json:"weight_sum"`
+	Mean                float64   `json:"mean"`
+	SquaredDeviationSum float64   `json:"squared_deviation_sum"`
+	BuyWeightSum        float64   `json:"buy_weight_sum"`
+	BuyValueWeightSum   float64   `json:"buy_value_weight_sum"`
+	SellWeightSum       float64   `json:"sell_weight_sum"`
+	SellValueWeightSum  float64   `json:"sell_value_weight_sum"`
+	TotalPoints         int       `json:"total_points"`
+}
+
+// Snapshot returns the decayed sums. They are decayed to the last timestamp.
+func (c *DecayCalculator) Snapshot() (json.RawMessage, error) {
+	return json.Marshal(decayState{
+		Kind:                decayKind,
+		LastTimestamp:       c.lastTimestamp,
+		FirstTimestamp:      c.firstTimestamp,
+		WeightSum:           c.weightSum,
+		Mean:                c.mean,
+		SquaredDeviationSum: c.squaredDeviationSum,
+		BuyWeightSum:        c.buyWeightSum,
+		BuyValueWeightSum:   c.buyValueWeightSum,
+		SellWeightSum:       c.sellWeightSum,
+		SellValueWeightSum:  c.sellValueWeightSum,
+		TotalPoints:         c.totalPoints,
+	})
+}
+
+// decayKind is the kind of the decaying averagers. The sums saved with another half-life are still a weighted average so they can be restored.
+const decayKind = "decay"
+
+// Restore replaces the sums with the sums of the snapshot. They are decayed by the time elapsed when the next point is added.
+// It returns ErrSnapshotMismatch if the snapshot was not taken from a decaying averager.
+func (c *DecayCalculator) Restore(state json.RawMessage) error {
+	return restore(c, state)
+}
+
+func (c *DecayCalculator) prepareRestore(state json.RawMessage) (func(), error) {
+	var s decayState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return nil, err
+	}
+
+	if err := checkKind(s.Kind, decayKind); err != nil {
+		return nil, err
+	}
+
+	return func() { c.restore(s) }, nil
+}
+
+func (c *DecayCalculator) restore(s decayState) {
+	c.lastTimestamp = s.LastTimestamp
+	c.firstTimestamp = s.FirstTimestamp
+	c.weightSum = s.WeightSum
+	c.mean = s.Mean
+	c.squaredDeviationSum = s.SquaredDeviationSum
+	c.buyWeightSum = s.BuyWeightSum
+	c.buyValueWeightSum = s.BuyValueWeightSum
+	c.sellWeightSum = s.SellWeightSum
+	c.sellValueWeightSum = s.SellValueWeightSum
+	c.totalPoints = s.TotalPoints
+}
+
+type twapState struct {
+	sequenceState
+	Name     string             `json:"name"`
+	SinceGap int                `json:"since_gap"`
+	Points   []entity.DataPoint `json:"points"`
+}
+
+// Snapshot returns the last sequence and the prices of the window.
+func (c *TWAPCalculator) Snapshot() (json.RawMessage, error) {
+	s := twapState{
+		sequenceState: c.sequenceGuard.state(),
+		Name:          c.name,
+		SinceGap:      c.sinceGap,
+		Points:        make([]entity.DataPoint, 0, c.points.Size()),
+	}
+
+	c.points.Do(func(p entity.DataPoint) {
+		s.Points = append(s.Points, p)
+	})
+
+	return json.Marshal(s)
+}
+
+// Restore replaces the last sequence and the prices of the window with the snapshot.
+// It returns ErrSnapshotMismatch if the snapshot was taken from another horizon.
+func (c *TWAPCalculator) Restore(state json.RawMessage) error {
+	return restore(c, state)
+}
+
+func (c *TWAPCalculator) prepareRestore(state json.RawMessage) (func(), error) {
+	var s twapState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return nil, err
+	}
+
+	if s.Name != c.name {
+		return nil, fmt.Errorf("%w: horizon %q restored into %q", ErrSnapshotMismatch, s.Name, c.name)
+	}
+
+	return func() { c.restore(s) }, nil
+}
+
+func (c *TWAPCalculator) restore(s twapState) {
+	for c.points.Size() > 0 {
+		c.points.Pop()
+	}
+
+	c.priceDurationSum = uint128{}
+	c.sequenceGuard.restore(s.sequenceState)
+	c.sinceGap = s.SinceGap
+
+	for _, p := range s.Points {
+		c.Add(p)
+	}
+}
+
+type pairState struct {
+	sequenceState
+	LastTimestamp time.Time      `json:"last_timestamp"`
+	Horizons      []horizonState `json:"horizons"`
+}
+
+type horizonState struct {
+	Name     string          `json:"name"`
+	SinceGap int             `json:"since_gap"`
+	State    json.RawMessage `json:"state"`
+}
+
+// Snapshot returns the last sequence and the state of each horizon.
+// The horizons whose averager cannot be saved are not part of the snapshot.
+func (c *TradingPairAvgCalculator) Snapshot() (json.RawMessage, error) {
+	s := pairState{
+		sequenceState: c.sequenceGuard.state(),
+		LastTimestamp: c.lastTimestamp,
+		Horizons:      make([]horizonState, 0, len(c.horizons)),
+	}
+
+	for _, h := range c.horizons {
+		calc, ok := h.calc.(snapshotter)
+		if !ok {
+			continue
+		}
+
+		state, err := calc.Snapshot()
+		if err != nil {
+			return nil, fmt.Errorf("cannot save horizon %q: %w", h.name, err)
+		}
+
+		s.Horizons = append(s.Horizons, horizonState{Name: h.name, SinceGap: h.sinceGap, State: state})
+	}
+
+	return json.Marshal(s)
+}
+
+// Restore restores the last sequence and the horizons of the snapshot. Horizons are matched by name:
+// the horizons missing from the snapshot start empty and the saved horizons which are no longer configured are dropped.
+// The restore is all or nothing: if a horizon cannot be restored (e.g. ErrSnapshotMismatch when it changed of kind), no horizon is.
+func (c *TradingPairAvgCalculator) Restore(state json.RawMessage) error {
+	return restore(c, state)
+}
+
+func (c *TradingPairAvgCalculator) prepareRestore(state json.RawMessage) (func(), error) {
+	var s pairState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return nil, err
+	}
+
+	saved := make(map[string]horizonState, len(s.Horizons))
+	for _, h := range s.Horizons {
+		saved[h.Name] = h
+	}
+
+	// every horizon is checked before any is restored
+	applies := make([]func(), 0, len(c.horizons))
+
+	for i := range c.horizons {
+		h := &c.horizons[i]
+
+		hs, found := saved[h.name]
+		if !found {
+			continue
+		}
+
+		calc, ok := h.calc.(snapshotter)
+		if !ok {
+			continue
+		}
+
+		apply, err := calc.prepareRestore(hs.State)
+		if err != nil {
+			return nil, fmt.Errorf("cannot restore horizon %q: %w", h.name, err)
+		}
+
+		sinceGap := hs.SinceGap
+
+		applies = append(applies, func() {
+			apply()
+			h.sinceGap = sinceGap
+		})
+	}
+
+	if len(applies) == 0 && len(s.Horizons) > 0 {
+		return nil, fmt.Errorf("%w: no horizon in common", ErrSnapshotMismatch)
+	}
+
+	return func() {
+		for _, apply := range applies {
+			apply()
+		}
+
+		if dropped := len(s.Horizons) - len(applies); dropped > 0 {
+			log.GetLogger().Warningf("%d saved horizons are no longer configured. dropped", dropped)
+		}
+
+		c.sequenceGuard.restore(s.sequenceState)
+		c.lastTimestamp = s.LastTimestamp
+	}, nil
+}
+
+// Snapshot returns the reference window of the filter. The number of rejected tickers is a statistic of the process and is not saved.
+func (f *OutlierFilter) Snapshot() (json.RawMessage, error) {
+	return f.reference.Snapshot()
+}
+
+// Restore replaces the reference window with the snapshot so the filter does not accept every trade again after a restart.
+func (f *OutlierFilter) Restore(state json.RawMessage) error {
+	return f.reference.Restore(state)
+}
+
+type emaState struct {
+	sequenceState
+	Kind  string  `json:"kind"`
+	EMA   float64 `json:"ema"`
+	Count int     `json:"count"`
+}
+
+// Snapshot returns the last sequence and the moving average.
+func (e *EMAIndicator) Snapshot() (json.RawMessage, error) {
+	return json.Marshal(emaState{sequenceState: e.sequenceGuard.state(), Kind: "ema", EMA: e.ema, Count: e.count})
+}
+
+// Restore replaces the last sequence and the moving average with the snapshot.
+// It returns ErrSnapshotMismatch if the snapshot was taken from another kind of indicator.
+func (e *EMAIndicator) Restore(state json.RawMessage) error {
+	var s emaState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+
+	if err := checkKind(s.Kind, "ema"); err != nil {
+		return err
+	}
+
+	e.sequenceGuard.restore(s.sequenceState)
+	e.ema = s.EMA
+	e.count = s.Count
+
+	return nil
+}
+
+type rsiState struct {
+	sequenceState
+	Kind      string  `json:"kind"`
+	LastPrice float64 `json:"last_price"`
+	AvgGain   float64 `json:"avg_gain"`
+	AvgLoss   float64 `json:"avg_loss"`
+	Changes   int     `json:"changes"`
+	HasPrice  bool    `json:"has_price"`
+}
+
+// Snapshot returns the last sequence, the last price and the average gain and loss.
+func (r *RSIIndicator) Snapshot() (json.RawMessage, error) {
+	return json.Marshal(rsiState{
+		sequenceState: r.sequenceGuard.state(),
+		Kind:          "rsi",
+		LastPrice:     r.lastPrice,
+		AvgGain:       r.avgGain,
+		AvgLoss:       r.avgLoss,
+		Changes:       r.changes,
+		HasPrice:      r.hasPrice,
+	})
+}
+
+// Restore replaces the last sequence, the last price and the average gain and loss with the snapshot.
+// It returns ErrSnapshotMismatch if the snapshot was taken from another kind of indicator.
+func (r *RSIIndicator) Restore(state json.RawMessage) error {
+	var s rsiState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+
+	if err := checkKind(s.Kind, "rsi"); err != nil {
+		return err
+	}
+
+	r.sequenceGuard.restore(s.sequenceState)
+	r.lastPrice = s.LastPrice
+	r.avgGain = s.AvgGain
+	r.avgLoss = s.AvgLoss
+	r.changes = s.Changes
+	r.hasPrice = s.HasPrice
+
+	return nil
+}
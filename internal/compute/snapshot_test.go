@@ -0,0 +1,273 @@
+package compute_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/compute"
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestCalculatorSnapshot(t *testing.T) {
+	c := compute.NewTimeCalculator(time.Minute)
+	c.EnableOrderStatistics()
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= 5; i++ {
+		c.Add(entity.DataPoint{Value: entity.DecimalFromInt(int64(i)), Volume: entity.DecimalFromInt(1), Side: entity.Buy, Timestamp: start.Add(time.Duration(i) * 20 * time.Second)})
+	}
+
+	state, err := c.Snapshot()
+	assert.Nil(t, err)
+
+	restored := compute.NewTimeCalculator(time.Minute)
+	restored.EnableOrderStatistics()
+	assert.Nil(t, restored.Restore(state))
+
+	avg, totalPoints := c.ComputeAverage()
+	restoredAvg, restoredPoints := restored.ComputeAverage()
+	assert.Equal(t, avg, restoredAvg)
+	assert.Equal(t, totalPoints, restoredPoints)
+	assert.Equal(t, c.ComputeStats(), restored.ComputeStats())
+	assert.Equal(t, c.ComputeOrderFlow(), restored.ComputeOrderFlow())
+
+	median, _ := c.ComputePercentile(50)
+	restoredMedian, _ := restored.ComputePercentile(50)
+	assert.Equal(t, median, restoredMedian)
+
+	// the restored window keeps evicting
+	c.Add(entity.DataPoint{Value: entity.DecimalFromInt(6), Volume: entity.DecimalFromInt(1), Timestamp: start.Add(2 * time.Minute)})
+	restored.Add(entity.DataPoint{Value: entity.DecimalFromInt(6), Volume: entity.DecimalFromInt(1), Timestamp: start.Add(2 * time.Minute)})
+
+	avg, totalPoints = c.ComputeAverage()
+	restoredAvg, restoredPoints = restored.ComputeAverage()
+	assert.Equal(t, avg, restoredAvg)
+	assert.Equal(t, totalPoints, restoredPoints)
+}
+
+func TestCalculatorSnapshotSmallerWindow(t *testing.T) {
+	c := compute.NewCalculator(5)
+
+	for i := 1; i <= 5; i++ {
+		c.Add(entity.DataPoint{Value: entity.DecimalFromInt(int64(i)), Volume: entity.DecimalFromInt(1)})
+	}
+
+	state, err := c.Snapshot()
+	assert.Nil(t, err)
+
+	restored := compute.NewCalculator(2)
+	assert.Nil(t, restored.Restore(state))
+
+	// only the two newest points are kept: (4 + 5) / 2
+	avg, totalPoints := restored.ComputeAverage()
+	assert.Equal(t, entity.MustParseDecimal("4.5"), avg)
+	assert.Equal(t, 2, totalPoints)
+}
+
+func TestSessionCalculatorSnapshot(t *testing.T) {
+	schedule, err := compute.ParseSchedule("@daily")
+	assert.Nil(t, err)
+
+	c := compute.NewSessionCalculator(schedule)
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+	c.Add(entity.DataPoint{Value: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1), Side: entity.Sell, Timestamp: start})
+	c.Add(entity.DataPoint{Value: entity.DecimalFromInt(2), Volume: entity.DecimalFromInt(3), Side: entity.Buy, Timestamp: start.Add(time.Hour)})
+
+	state, err := c.Snapshot()
+	assert.Nil(t, err)
+
+	restored := compute.NewSessionCalculator(schedule)
+	assert.Nil(t, restored.Restore(state))
+
+	avg, totalPoints := c.ComputeAverage()
+	restoredAvg, restoredPoints := restored.ComputeAverage()
+	assert.Equal(t, avg, restoredAvg)
+	assert.Equal(t, totalPoints, restoredPoints)
+	assert.Equal(t, c.ComputeStdDev(), restored.ComputeStdDev())
+	assert.Equal(t, c.ComputeOrderFlow(), restored.ComputeOrderFlow())
+	assert.Equal(t, c.SessionStart(), restored.SessionStart())
+}
+
+func TestDecayCalculatorSnapshot(t *testing.T) {
+	c := compute.NewDecayCalculator(time.Minute)
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+	c.Add(entity.DataPoint{Value: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1), Timestamp: start})
+	c.Add(entity.DataPoint{Value: entity.DecimalFromInt(2), Volume: entity.DecimalFromInt(1), Timestamp: start.Add(time.Minute)})
+
+	state, err := c.Snapshot()
+	assert.Nil(t, err)
+
+	restored := compute.NewDecayCalculator(time.Minute)
+	assert.Nil(t, restored.Restore(state))
+
+	c.Add(entity.DataPoint{Value: entity.DecimalFromInt(3), Volume: entity.DecimalFromInt(1), Timestamp: start.Add(2 * time.Minute)})
+	restored.Add(entity.DataPoint{Value: entity.DecimalFromInt(3), Volume: entity.DecimalFromInt(1), Timestamp: start.Add(2 * time.Minute)})
+
+	avg, _ := c.ComputeAverage()
+	restoredAvg, _ := restored.ComputeAverage()
+	assert.Equal(t, avg, restoredAvg)
+	assert.Equal(t, c.ComputeStats(), restored.ComputeStats())
+}
+
+func TestTWAPCalculatorSnapshot(t *testing.T) {
+	c := compute.NewTWAPCalculator("5m", 5*time.Minute)
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+	_, _ = c.ProcessTicker(entity.Ticker{Sequence: 1, Price: entity.DecimalFromInt(1), Timestamp: start})
+	_, _ = c.ProcessTicker(entity.Ticker{Sequence: 2, Price: entity.DecimalFromInt(3), Timestamp: start.Add(time.Minute)})
+	c.ProcessHeartBeat(entity.HeartBeat{Sequence: 2})
+
+	state, err := c.Snapshot()
+	assert.Nil(t, err)
+
+	restored := compute.NewTWAPCalculator("5m", 5*time.Minute)
+	assert.Nil(t, restored.Restore(state))
+
+	ticker := entity.Ticker{Sequence: 3, Price: entity.DecimalFromInt(2), Timestamp: start.Add(3 * time.Minute)}
+	results, _ := c.ProcessTicker(ticker)
+	restoredResults, err := restored.ProcessTicker(ticker)
+	assert.Nil(t, err)
+	assert.Equal(t, results, restoredResults)
+
+	// the last sequence is restored as well
+	_, err = restored.ProcessTicker(entity.Ticker{Sequence: 1, Price: entity.DecimalFromInt(2), Timestamp: start.Add(4 * time.Minute)})
+	assert.ErrorIs(t, err, compute.ErrSequenceNotIncreasing)
+
+	other := compute.NewTWAPCalculator("1h", time.Hour)
+	assert.ErrorIs(t, other.Restore(state), compute.ErrSnapshotMismatch)
+}
+
+func TestCurrencyAvgCalculatorSnapshot(t *testing.T) {
+	newCalculator := func() *compute.TradingPairAvgCalculator {
+		c := compute.NewTradingPairAvgCalculator()
+		c.AddHorizon("3", compute.NewCalculator(3))
+		c.AddHorizon("5m", compute.NewTimeCalculator(5*time.Minute))
+
+		return c
+	}
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+
+	c := newCalculator()
+	c.ProcessHeartBeat(entity.HeartBeat{Sequence: 1})
+
+	for i := 1; i <= 4; i++ {
+		_, err := c.ProcessTicker(entity.Ticker{Sequence: int64(i), Price: entity.DecimalFromInt(int64(i)), Volume: entity.DecimalFromInt(1), Timestamp: start.Add(time.Duration(i) * time.Second)})
+		assert.Nil(t, err)
+	}
+
+	state, err := c.Snapshot()
+	assert.Nil(t, err)
+
+	restored := newCalculator()
+	assert.Nil(t, restored.Restore(state))
+
+	ticker := entity.Ticker{Sequence: 5, Price: entity.DecimalFromInt(5), Volume: entity.DecimalFromInt(1), Timestamp: start.Add(5 * time.Second)}
+	results, _ := c.ProcessTicker(ticker)
+	restoredResults, err := restored.ProcessTicker(ticker)
+	assert.Nil(t, err)
+	assert.Equal(t, results, restoredResults)
+
+	// no horizon in common
+	other := compute.NewTradingPairAvgCalculator()
+	other.AddHorizon("1h", compute.NewTimeCalculator(time.Hour))
+	assert.ErrorIs(t, other.Restore(state), compute.ErrSnapshotMismatch)
+
+	// the horizon "5m" is now a count window: nothing is restored, not even the horizon "3"
+	changed := compute.NewTradingPairAvgCalculator()
+	changed.AddHorizon("3", compute.NewCalculator(3))
+	changed.AddHorizon("5m", compute.NewCalculator(300))
+	assert.ErrorIs(t, changed.Restore(state), compute.ErrSnapshotMismatch)
+
+	results, err = changed.ProcessTicker(ticker)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, results[0].TotalPoints, "the horizon should not be restored")
+}
+
+func TestSnapshotKindMismatch(t *testing.T) {
+	state, err := compute.NewTimeCalculator(time.Minute).Snapshot()
+	assert.Nil(t, err)
+
+	assert.Nil(t, compute.NewTimeCalculator(time.Hour).Restore(state), "a resized window is restored")
+	assert.ErrorIs(t, compute.NewCalculator(10).Restore(state), compute.ErrSnapshotMismatch)
+	assert.ErrorIs(t, compute.NewVolumeCalculator(entity.DecimalFromInt(10)).Restore(state), compute.ErrSnapshotMismatch)
+	assert.ErrorIs(t, compute.NewDecayCalculator(time.Minute).Restore(state), compute.ErrSnapshotMismatch)
+
+	daily, _ := compute.ParseSchedule("@daily")
+	weekly, _ := compute.ParseSchedule("@weekly")
+
+	state, err = compute.NewSessionCalculator(daily).Snapshot()
+	assert.Nil(t, err)
+	assert.ErrorIs(t, compute.NewSessionCalculator(weekly).Restore(state), compute.ErrSnapshotMismatch)
+}
+
+func TestOutlierFilterSnapshot(t *testing.T) {
+	f := compute.NewOutlierFilter(3, 2, 0)
+
+	for _, p := range []int64{99, 100, 101} {
+		assert.Nil(t, f.Check(entity.Ticker{Price: entity.DecimalFromInt(p), Volume: entity.DecimalFromInt(1)}))
+	}
+
+	state, err := f.Snapshot()
+	assert.Nil(t, err)
+
+	// the restored filter rejects at once instead of accepting everything until its reference window is full
+	restored := compute.NewOutlierFilter(3, 2, 0)
+	assert.Nil(t, restored.Restore(state))
+	assert.ErrorIs(t, restored.Check(entity.Ticker{Price: entity.DecimalFromInt(120), Volume: entity.DecimalFromInt(1)}), compute.ErrOutlier)
+}
+
+// snapshotter is implemented by the indicators which can be checkpointed.
+type snapshotter interface {
+	Snapshot() (json.RawMessage, error)
+	Restore(state json.RawMessage) error
+}
+
+func TestIndicatorSnapshot(t *testing.T) {
+	for _, params := range []struct {
+		indicator string
+		params    string
+	}{
+		{"ema", `{"period": 3}`},
+		{"rsi", `{"period": 2}`},
+	} {
+		newIndicator := func() compute.Indicator {
+			i, err := compute.NewIndicator(params.indicator, "", json.RawMessage(params.params))
+			assert.Nil(t, err)
+
+			return i
+		}
+
+		i := newIndicator()
+		for seq, price := range []int64{10, 12, 11} {
+			_, err := i.ProcessTicker(entity.Ticker{Sequence: int64(seq + 1), Price: entity.DecimalFromInt(price), Volume: entity.DecimalFromInt(1)})
+			assert.Nil(t, err)
+		}
+
+		state, err := i.(snapshotter).Snapshot()
+		assert.Nil(t, err, params.indicator)
+
+		restored := newIndicator()
+		assert.Nil(t, restored.(snapshotter).Restore(state), params.indicator)
+
+		ticker := entity.Ticker{Sequence: 4, Price: entity.DecimalFromInt(10), Volume: entity.DecimalFromInt(1)}
+		values, _ := i.ProcessTicker(ticker)
+		restoredValues, err := restored.ProcessTicker(ticker)
+		assert.Nil(t, err, params.indicator)
+		assert.Equal(t, values, restoredValues, params.indicator)
+	}
+
+	// the state of another indicator is rejected
+	ema, _ := compute.NewIndicator("ema", "", nil)
+	rsi, _ := compute.NewIndicator("rsi", "", nil)
+
+	state, err := ema.(snapshotter).Snapshot()
+	assert.Nil(t, err)
+	assert.ErrorIs(t, rsi.(snapshotter).Restore(state), compute.ErrSnapshotMismatch)
+}
@@ -0,0 +1,220 @@
+package compute
+
+import (
+	"math/big"
+	"math/bits"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+// uint128 is an unsigned 128 bits integer.
+// It is used to sum exactly the products value*volume whose units are 10^-16 and do not fit in 64 bits.
+type uint128 struct {
+	hi uint64
+	lo uint64
+}
+
+// mul64 returns the 128 bits product a*b.
+func mul64(a, b uint64) uint128 {
+	hi, lo := bits.Mul64(a, b)
+
+	return uint128{hi, lo}
+}
+
+func (u uint128) Add(v uint128) uint128 {
+	lo, carry := bits.Add64(u.lo, v.lo, 0)
+	hi, _ := bits.Add64(u.hi, v.hi, carry)
+
+	return uint128{hi, lo}
+}
+
+func (u uint128) Sub(v uint128) uint128 {
+	lo, borrow := bits.Sub64(u.lo, v.lo, 0)
+	hi, _ := bits.Sub64(u.hi, v.hi, borrow)
+
+	return uint128{hi, lo}
+}
+
+// DivRound returns u/y rounded to the nearest integer. Halves are rounded up.
+func (u uint128) DivRound(y uint64) uint128 {
+	qhi, rhi := u.hi/y, u.hi%y
+	qlo, r := bits.Div64(rhi, u.lo, y)
+
+	q := uint128{qhi, qlo}
+	if r >= y-r {
+		q = q.Add(uint128{0, 1})
+	}
+
+	return q
+}
+
+// big returns u as a big integer.
+func (u uint128) big() *big.Int {
+	b := new(big.Int).SetUint64(u.hi)
+	b.Lsh(b, 64)
+
+	return b.Or(b, new(big.Int).SetUint64(u.lo))
+}
+
+// uint192 is an unsigned 192 bits integer.
+// It is used to sum exactly the products value^2*volume whose units are 10^-24 and do not fit in 128 bits.
+// Additions and substractions wrap around so the sum is exact as long as the true sum fits in 192 bits.
+type uint192 struct {
+	hi  uint64
+	mid uint64
+	lo  uint64
+}
+
+// mul128 returns the 192 bits product u*y.
+func mul128(u uint128, y uint64) uint192 {
+	c1, r0 := bits.Mul64(u.lo, y)
+	c2, r1 := bits.Mul64(u.hi, y)
+	r1, carry := bits.Add64(r1, c1, 0)
+
+	return uint192{c2 + carry, r1, r0}
+}
+
+func (u uint192) Add(v uint192) uint192 {
+	lo, carry := bits.Add64(u.lo, v.lo, 0)
+	mid, carry := bits.Add64(u.mid, v.mid, carry)
+	hi, _ := bits.Add64(u.hi, v.hi, carry)
+
+	return uint192{hi, mid, lo}
+}
+
+func (u uint192) Sub(v uint192) uint192 {
+	lo, borrow := bits.Sub64(u.lo, v.lo, 0)
+	mid, borrow := bits.Sub64(u.mid, v.mid, borrow)
+	hi, _ := bits.Sub64(u.hi, v.hi, borrow)
+
+	return uint192{hi, mid, lo}
+}
+
+// big returns u as a big integer.
+func (u uint192) big() *big.Int {
+	b := new(big.Int).SetUint64(u.hi)
+	b.Lsh(b, 64)
+	b.Or(b, new(big.Int).SetUint64(u.mid))
+	b.Lsh(b, 64)
+
+	return b.Or(b, new(big.Int).SetUint64(u.lo))
+}
+
+// vwapSums holds the running sums of a set of points needed to compute their volume weighted average and standard deviation.
+type vwapSums struct {
+	// totalVolume is the sum of all volumes
+	totalVolume entity.Decimal
+	// valueVolumeSum is the Sum(point.value * point.volume) of all points.
+	// The product of two decimals has 2*DecimalPlaces digits after the decimal point so it is kept in 128 bits.
+	valueVolumeSum uint128
+	// valueSquareVolumeSum is the Sum(point.value^2 * point.volume) of all points.
+	// It has 3*DecimalPlaces digits after the decimal point so it is kept in 192 bits.
+	valueSquareVolumeSum uint192
+}
+
+func (s *vwapSums) Add(p entity.DataPoint) {
+	s.totalVolume = s.totalVolume.Add(p.Volume)
+	s.valueVolumeSum = s.valueVolumeSum.Add(valueVolume(p))
+	s.valueSquareVolumeSum = s.valueSquareVolumeSum.Add(valueSquareVolume(p))
+}
+
+func (s *vwapSums) Remove(p entity.DataPoint) {
+	s.totalVolume = s.totalVolume.Sub(p.Volume)
+	s.valueVolumeSum = s.valueVolumeSum.Sub(valueVolume(p))
+	s.valueSquareVolumeSum = s.valueSquareVolumeSum.Sub(valueSquareVolume(p))
+}
+
+// Average returns Sum(value * volume) / Sum(volume) rounded to the nearest decimal. It returns 0 if there is no volume.
+func (s *vwapSums) Average() entity.Decimal {
+	if s.totalVolume.Units() <= 0 {
+		return entity.Decimal{}
+	}
+
+	// units of 10^-2*DecimalPlaces divided by units of 10^-DecimalPlaces give units of 10^-DecimalPlaces
+	return entity.NewDecimal(int64(s.valueVolumeSum.DivRound(uint64(s.totalVolume.Units())).lo))
+}
+
+// StdDev returns the volume weighted standard deviation sqrt(Sum(value^2 * volume) / Sum(volume) - average^2) rounded to the nearest decimal.
+// It returns 0 if there is no volume.
+// The variance is computed on the exact sums as (V*S - P^2) / V^2 where V is the total volume, S the sum of value^2 * volume
+// and P the sum of value * volume. The difference is exact so there is no cancellation when the deviation is tiny compared to the price.
+func (s *vwapSums) StdDev() entity.Decimal {
+	if s.totalVolume.Units() <= 0 {
+		return entity.Decimal{}
+	}
+
+	volume := new(big.Int).SetUint64(uint64(s.totalVolume.Units()))
+	sum := s.valueVolumeSum.big()
+
+	// units of 10^-4*DecimalPlaces
+	n := new(big.Int).Mul(volume, s.valueSquareVolumeSum.big())
+	n.Sub(n, sum.Mul(sum, sum))
+
+	if n.Sign() <= 0 {
+		return entity.Decimal{}
+	}
+
+	// sqrt(n) is in units of 10^-2*DecimalPlaces: divided by the volume it gives units of 10^-DecimalPlaces
+	n.Sqrt(n)
+
+	q, r := n.QuoRem(n, volume, new(big.Int))
+	if r.Lsh(r, 1).Cmp(volume) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+
+	return entity.NewDecimal(q.Int64())
+}
+
+// valueVolume returns the exact product p.Value * p.Volume in units of 10^-2*DecimalPlaces.
+func valueVolume(p entity.DataPoint) uint128 {
+	return mul64(uint64(p.Value.Units()), uint64(p.Volume.Units()))
+}
+
+// valueSquareVolume returns the exact product p.Value^2 * p.Volume in units of 10^-3*DecimalPlaces.
+func valueSquareVolume(p entity.DataPoint) uint192 {
+	return mul128(mul64(uint64(p.Value.Units()), uint64(p.Value.Units())), uint64(p.Volume.Units()))
+}
+
+// flowSums holds the running sums of the buy and the sell points. Points without side are not counted.
+type flowSums struct {
+	buy  vwapSums
+	sell vwapSums
+}
+
+func (s *flowSums) Add(p entity.DataPoint) {
+	switch p.Side {
+	case entity.Buy:
+		s.buy.Add(p)
+	case entity.Sell:
+		s.sell.Add(p)
+	}
+}
+
+func (s *flowSums) Remove(p entity.DataPoint) {
+	switch p.Side {
+	case entity.Buy:
+		s.buy.Remove(p)
+	case entity.Sell:
+		s.sell.Remove(p)
+	}
+}
+
+func (s *flowSums) OrderFlow() entity.OrderFlow {
+	return orderFlow(s.buy.Average(), s.sell.Average(), s.buy.totalVolume, s.sell.totalVolume)
+}
+
+// orderFlow returns the order flow of the buy and sell averages and volumes.
+func orderFlow(buyAverage, sellAverage, buyVolume, sellVolume entity.Decimal) entity.OrderFlow {
+	flow := entity.OrderFlow{
+		BuyAverage:  buyAverage,
+		SellAverage: sellAverage,
+		BuyVolume:   buyVolume,
+		SellVolume:  sellVolume,
+	}
+
+	if total := buyVolume.Add(sellVolume); total.Units() > 0 {
+		flow.Imbalance = float64(buyVolume.Sub(sellVolume).Units()) / float64(total.Units())
+	}
+
+	return flow
+}
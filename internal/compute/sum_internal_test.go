@@ -0,0 +1,41 @@
+package compute
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUint128(t *testing.T) {
+	// 2^64 - 1 squared does not fit in 64 bits
+	sum := mul64(math.MaxUint64, math.MaxUint64)
+	assert.Equal(t, uint128{math.MaxUint64 - 1, 1}, sum)
+
+	sum = sum.Add(mul64(2, 3))
+	assert.Equal(t, uint128{math.MaxUint64 - 1, 7}, sum)
+
+	sum = sum.Sub(mul64(math.MaxUint64, math.MaxUint64))
+	assert.Equal(t, uint128{0, 6}, sum)
+
+	// 6/4 = 1.5 rounded to 2 and 5/4 = 1.25 rounded to 1
+	assert.Equal(t, uint128{0, 2}, sum.DivRound(4))
+	assert.Equal(t, uint128{0, 1}, uint128{0, 5}.DivRound(4))
+
+	assert.Equal(t, uint128{1, 0}, uint128{2, 0}.DivRound(2))
+}
+
+func TestUint192(t *testing.T) {
+	max := uint128{math.MaxUint64, math.MaxUint64}
+
+	// (2^128 - 1) * (2^64 - 1) = 2^192 - 2^128 - 2^64 + 1
+	sum := mul128(max, math.MaxUint64)
+	assert.Equal(t, uint192{math.MaxUint64 - 1, math.MaxUint64, 1}, sum)
+
+	sum = sum.Add(mul128(uint128{0, 2}, 3))
+	assert.Equal(t, uint192{math.MaxUint64 - 1, math.MaxUint64, 7}, sum)
+
+	sum = sum.Sub(mul128(max, math.MaxUint64))
+	assert.Equal(t, uint192{0, 0, 6}, sum)
+	assert.Equal(t, "6", sum.big().String())
+}
@@ -0,0 +1,190 @@
+package compute
+
+import (
+	"time"
+
+	"github.com/tupyy/vwap/internal/entity"
+	"github.com/tupyy/vwap/internal/log"
+)
+
+// TWAPCalculator computes the time weighted average price of a trading pair over a time window.
+// Each price is weighted by how long it was the last traded price, using the timestamp of the tickers.
+// It implements the same contract as TradingPairAvgCalculator so both can be driven for the same pair.
+type TWAPCalculator struct {
+	sequenceGuard
+	// name -- name of the horizon
+	name string
+	// window -- duration of the window
+	window time.Duration
+	// points -- prices in the window. The oldest one may have been the last price before the start of the window.
+	points *ring
+	// priceDurationSum -- Sum(price * duration) of the prices in the window except the newest one
+	// whose duration is not known yet. The duration is in nanoseconds so the products are kept in 128 bits.
+	priceDurationSum uint128
+	// quoteIncrement -- the average is rounded to a multiple of quoteIncrement. Zero means no rounding.
+	quoteIncrement entity.Decimal
+	// warmUp -- the results are marked warming up until the window meets these thresholds
+	warmUp WarmUp
+	// markDegraded -- if true, the results are marked degraded after a gap until the window has turned over
+	markDegraded bool
+	// gap -- true if trades were lost since the last ticker
+	gap bool
+	// sinceGap is the number of prices added since the last gap or -1 if the window holds no price older than the gap.
+	sinceGap int
+}
+
+// NewTWAPCalculator returns a calculator of the time weighted average over the last window duration.
+// name is the name of the horizon reported in the results.
+func NewTWAPCalculator(name string, window time.Duration) *TWAPCalculator {
+	return &TWAPCalculator{
+		name:     name,
+		window:   window,
+		points:   newRing(DefaultVolumeSize),
+		sinceGap: -1,
+	}
+}
+
+// SetQuoteIncrement sets the quote increment of the product. The average is rounded to a multiple of it.
+func (c *TWAPCalculator) SetQuoteIncrement(increment entity.Decimal) {
+	c.quoteIncrement = increment
+}
+
+// SetWarmUp sets the thresholds the window must meet before its results stop being marked warming up.
+// The volume threshold is ignored: the prices of the window have no volume.
+func (c *TWAPCalculator) SetWarmUp(w WarmUp) {
+	w.MinVolume = entity.Decimal{}
+	c.warmUp = w
+}
+
+// SetMarkDegraded sets whether the results are marked degraded after a gap in the trades (see MarkGap).
+// A result stays degraded until all the prices of its window were received after the gap.
+func (c *TWAPCalculator) SetMarkDegraded(mark bool) {
+	c.markDegraded = mark
+}
+
+// MarkGap records that trades of the product were lost before the next ticker.
+func (c *TWAPCalculator) MarkGap() {
+	c.gap = true
+}
+
+// ProcessTicker adds the price of the ticker and returns the time weighted average at the timestamp of the ticker.
+func (c *TWAPCalculator) ProcessTicker(t entity.Ticker) ([]entity.AverageResult, error) {
+	if err := c.checkTicker(t); err != nil {
+		return nil, err
+	}
+
+	c.Add(entity.DataPoint{Value: t.Price, Timestamp: t.Timestamp})
+
+	avg, totalPoints := c.ComputeAverage()
+	stats := c.ComputeStats()
+
+	degraded := false
+	if c.markDegraded {
+		degraded = windowDegraded(&c.sinceGap, c.gap, totalPoints)
+	}
+
+	c.gap = false
+
+	log.GetLogger().Debugf("new ticker processed: %+v. new twap: %s", t, avg)
+
+	return []entity.AverageResult{
+		{
+			ProductID:    t.ProductID,
+			Method:       entity.TWAP,
+			Horizon:      c.name,
+			Average:      avg.Round(c.quoteIncrement),
+			ExactAverage: avg,
+			TotalPoints:  totalPoints,
+			Window:       stats,
+			WarmingUp:    !c.warmUp.Met(stats),
+			Degraded:     degraded,
+		},
+	}, nil
+}
+
+// Add adds the price of p at the timestamp of p. Points older than the newest point are ignored.
+func (c *TWAPCalculator) Add(p entity.DataPoint) {
+	if newest, ok := c.newest(); ok {
+		if p.Timestamp.Before(newest.Timestamp) {
+			log.GetLogger().Debugf("point %+v older than the last price %s. ignored", p, newest.Timestamp)
+
+			return
+		}
+
+		// the previous price lasted until now
+		c.priceDurationSum = c.priceDurationSum.Add(priceDuration(newest, p.Timestamp))
+	}
+
+	c.points.Push(p)
+
+	// evict the prices which stopped being the last price before the start of the window
+	start := p.Timestamp.Add(-c.window)
+
+	for c.points.Size() > 1 {
+		oldest, _ := c.points.Peek()
+		next := c.points.at(1)
+
+		if next.Timestamp.After(start) {
+			break
+		}
+
+		c.points.Pop()
+		c.priceDurationSum = c.priceDurationSum.Sub(priceDuration(oldest, next.Timestamp))
+	}
+}
+
+// ComputeAverage returns the time weighted average at the timestamp of the newest point.
+// If the window has no duration yet, the last price is returned.
+func (c *TWAPCalculator) ComputeAverage() (avg entity.Decimal, totalPoints int) {
+	newest, ok := c.newest()
+	if !ok {
+		return entity.Decimal{}, 0
+	}
+
+	oldest, _ := c.points.Peek()
+
+	// the oldest price may have started before the window: only its duration within the window is taken
+	start := newest.Timestamp.Add(-c.window)
+	if oldest.Timestamp.After(start) {
+		start = oldest.Timestamp
+	}
+
+	duration := newest.Timestamp.Sub(start)
+	if duration <= 0 {
+		return newest.Value, c.points.Size()
+	}
+
+	sum := c.priceDurationSum.Sub(priceDuration(oldest, start))
+
+	return entity.NewDecimal(int64(sum.DivRound(uint64(duration)).lo)), c.points.Size()
+}
+
+// ComputeStats returns the number of prices and the timestamps of the oldest and newest prices of the window.
+// The prices have no volume.
+func (c *TWAPCalculator) ComputeStats() entity.WindowStats {
+	newest, ok := c.newest()
+	if !ok {
+		return entity.WindowStats{}
+	}
+
+	oldest, _ := c.points.Peek()
+
+	return entity.WindowStats{
+		TotalPoints: c.points.Size(),
+		Start:       oldest.Timestamp,
+		End:         newest.Timestamp,
+	}
+}
+
+func (c *TWAPCalculator) newest() (entity.DataPoint, bool) {
+	if c.points.Size() == 0 {
+		return entity.DataPoint{}, false
+	}
+
+	return c.points.at(c.points.Size() - 1), true
+}
+
+// priceDuration returns p.Value * (end - p.Timestamp) in units of 10^-DecimalPlaces * nanoseconds.
+func priceDuration(p entity.DataPoint, end time.Time) uint128 {
+	return mul64(uint64(p.Value.Units()), uint64(end.Sub(p.Timestamp)))
+}
@@ -0,0 +1,117 @@
+package compute_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/compute"
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestTWAPCalculator(t *testing.T) {
+	calc := compute.NewTWAPCalculator("1m", time.Minute)
+
+	now := time.Now()
+
+	calc.Add(timedPoint("10", "1", now))
+
+	// no duration yet: the last price is returned
+	avg, totalPoints := calc.ComputeAverage()
+	assert.Equal(t, "10", avg.String(), "expect avg = 10")
+	assert.Equal(t, 1, totalPoints, "expect 1 computation point")
+
+	// the volume is not taken into account
+	calc.Add(timedPoint("20", "100", now.Add(10*time.Second)))
+	calc.Add(timedPoint("40", "1", now.Add(40*time.Second)))
+
+	// (10*10 + 20*30) / 40 = 17.5
+	avg, totalPoints = calc.ComputeAverage()
+	assert.Equal(t, "17.5", avg.String(), "expect avg = 17.5")
+	assert.Equal(t, 3, totalPoints, "expect 3 computation points")
+
+	// the window starts at 30s: (20*10 + 40*50) / 60 = 36.66666667
+	calc.Add(timedPoint("30", "1", now.Add(90*time.Second)))
+
+	avg, totalPoints = calc.ComputeAverage()
+	assert.Equal(t, "36.66666667", avg.String(), "expect avg = 36.6667")
+	assert.Equal(t, 3, totalPoints, "expect 3 computation points")
+
+	// a point older than the last price is ignored
+	calc.Add(timedPoint("1000", "1", now))
+
+	avg, _ = calc.ComputeAverage()
+	assert.Equal(t, "36.66666667", avg.String(), "expect avg = 36.6667")
+
+	// the last price lasted the whole window
+	calc.Add(timedPoint("50", "1", now.Add(10*time.Minute)))
+
+	avg, totalPoints = calc.ComputeAverage()
+	assert.Equal(t, "30", avg.String(), "expect avg = 30")
+	assert.Equal(t, 2, totalPoints, "expect 2 computation points")
+}
+
+func TestTWAPCalculatorProcessTicker(t *testing.T) {
+	c := compute.NewTWAPCalculator("5m", 5*time.Minute)
+
+	c.ProcessHeartBeat(entity.HeartBeat{Sequence: 2})
+
+	now := time.Now()
+
+	results, err := c.ProcessTicker(entity.Ticker{
+		Sequence:  2,
+		ProductID: "BTC-USD",
+		Price:     entity.DecimalFromInt(1),
+		Timestamp: now,
+	})
+
+	assert.Nil(t, err, "err should be nil")
+	assert.Equal(t, []entity.AverageResult{
+		{
+			ProductID:    "BTC-USD",
+			Method:       entity.TWAP,
+			Horizon:      "5m",
+			Average:      entity.DecimalFromInt(1),
+			ExactAverage: entity.DecimalFromInt(1),
+			TotalPoints:  1,
+			Window:       entity.WindowStats{TotalPoints: 1, Start: now, End: now},
+		},
+	}, results)
+
+	_, err = c.ProcessTicker(entity.Ticker{Sequence: 1})
+	assert.ErrorIs(t, err, compute.ErrSequenceNotIncreasing, "should have err seq not increasing")
+}
+
+func TestTWAPCalculatorWarmUpAndGaps(t *testing.T) {
+	c := compute.NewTWAPCalculator("1m", time.Minute)
+	c.SetMarkDegraded(true)
+	c.SetWarmUp(compute.WarmUp{
+		MinPoints: 2,
+		MinVolume: entity.DecimalFromInt(100),
+		MinSpan:   20 * time.Second,
+	})
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+
+	warmingUp := make([]bool, 0, 6)
+	degraded := make([]bool, 0, 6)
+
+	for i := 0; i < 6; i++ {
+		// trades are lost before the 4th price
+		if i == 3 {
+			c.MarkGap()
+		}
+
+		results, err := c.ProcessTicker(entity.Ticker{Price: entity.DecimalFromInt(1), Timestamp: start.Add(time.Duration(i) * 20 * time.Second)})
+		assert.Nil(t, err)
+
+		warmingUp = append(warmingUp, results[0].WarmingUp)
+		degraded = append(degraded, results[0].Degraded)
+	}
+
+	// the volume threshold does not apply to the prices
+	assert.Equal(t, []bool{true, false, false, false, false, false}, warmingUp)
+	// the window of 1m holds the prices of the last 60s and the price before them: 4 prices
+	assert.Equal(t, []bool{false, false, false, true, true, true}, degraded)
+}
@@ -1,48 +1,338 @@
 package compute
 
 import (
+	"sort"
+	"time"
+
 	"github.com/tupyy/vwap/internal/entity"
 	"github.com/tupyy/vwap/internal/log"
 )
 
+// DefaultDriftCheckInterval is the default number of points added between two drift checks.
+const DefaultDriftCheckInterval = 100_000
+
+// Drift holds the difference between the running sums and the sums recomputed from the window.
+// The sums of the average are exact so any drift of the volume or the average is a bug.
+type Drift struct {
+	// Volume -- drift of the total volume
+	Volume entity.Decimal
+	// Average -- drift of the average
+	Average entity.Decimal
+	// StdDev -- drift of the standard deviation
+	StdDev entity.Decimal
+}
+
+// Calculator computes the volume weighted average of the points in its window.
+// Values and volumes are expected to be positive.
 type Calculator struct {
-	// stack holds the points
-	stack *stack
-	// totalVolume is the sum of all volumes in the stack
-	totalVolume float64
-	// valueVolumeSum is the Sum(point.value * point.volume) of all points in the stack
-	valueVolumeSum float64
-	// maxSize is the max number of points used in calculation
+	// window holds the points
+	window *ring
+	// sums holds the running sums of all points in the window
+	sums vwapSums
+	// flow holds the running sums of the buy and sell points in the window
+	flow flowSums
+	// maxSize is the max number of points used in calculation. 0 means no limit.
 	maxSize int
+	// maxAge is the max age of the points used in calculation relative to the newest point. 0 means no limit.
+	maxAge time.Duration
+	// maxVolume is the volume covered by the window. The oldest point is split so that the window holds exactly maxVolume. 0 means no limit.
+	maxVolume entity.Decimal
+	// lastTimestamp is the newest timestamp seen by the calculator
+	lastTimestamp time.Time
+	// driftCheckInterval is the minimum number of points added between two drift checks. 0 disables the check.
+	driftCheckInterval int
+	// addedSinceCheck is the number of points added since the last drift check
+	addedSinceCheck int
+	// lastDrift is the drift observed at the last check
+	lastDrift Drift
+	// prices orders the prices of the window by volume for the median and the percentiles. Nil unless enabled.
+	prices *priceTree
+	// venues holds the running sums of the points of each venue. The key is the venue. Only the points with a venue are counted.
+	venues map[string]vwapSums
 }
 
+// NewCalculator returns a calculator which keeps the last size points.
 func NewCalculator(size int) *Calculator {
 	return &Calculator{
-		stack:   newStack(),
-		maxSize: size,
+		window:             newRing(size),
+		maxSize:            size,
+		driftCheckInterval: DefaultDriftCheckInterval,
+	}
+}
+
+// NewTimeCalculator returns a calculator which keeps the points whose timestamp is within maxAge of the newest point.
+func NewTimeCalculator(maxAge time.Duration) *Calculator {
+	return &Calculator{
+		window:             newRing(DefaultVolumeSize),
+		maxAge:             maxAge,
+		driftCheckInterval: DefaultDriftCheckInterval,
+	}
+}
+
+// NewVolumeCalculator returns a calculator which keeps the last maxVolume traded.
+// The oldest point is partially evicted, keeping only the part of its volume needed to cover exactly maxVolume.
+func NewVolumeCalculator(maxVolume entity.Decimal) *Calculator {
+	return &Calculator{
+		window:             newRing(DefaultVolumeSize),
+		maxVolume:          maxVolume,
+		driftCheckInterval: DefaultDriftCheckInterval,
 	}
 }
 
+// SetDriftCheckInterval sets the minimum number of points added between two drift checks. 0 disables the check.
+// The check is O(n) so it is never run more often than the size of the window.
+func (c *Calculator) SetDriftCheckInterval(n int) {
+	c.driftCheckInterval = n
+}
+
+// EnableOrderStatistics maintains the prices of the window ordered so the volume weighted median and percentiles can be computed.
+// It makes Add O(log n).
+func (c *Calculator) EnableOrderStatistics() {
+	if c.prices != nil {
+		return
+	}
+
+	c.prices = newPriceTree()
+	c.window.Do(c.prices.Add)
+}
+
 func (c *Calculator) Add(p entity.DataPoint) {
-	if c.stack.Size() == c.maxSize {
-		poppedPoint := c.stack.Pop()
+	if c.maxAge > 0 {
+		if p.Timestamp.After(c.lastTimestamp) {
+			c.lastTimestamp = p.Timestamp
+		}
+
+		// the point is already outside the window
+		if !p.Timestamp.After(c.lastTimestamp.Add(-c.maxAge)) {
+			log.GetLogger().Debugf("point %+v older than %s. ignored", p, c.maxAge)
+
+			return
+		}
+
+		c.evictOlderThan(c.lastTimestamp.Add(-c.maxAge))
+	}
+
+	if c.maxSize > 0 && c.window.Size() == c.maxSize {
+		c.pop()
+	}
 
-		// substract the volume of the poppedPoint from totalVolume
-		c.totalVolume -= poppedPoint.value.Volume
+	if c.maxAge > 0 {
+		// a late point is inserted in timestamp order so the oldest point stays at the head of the window
+		c.window.Insert(c.insertIndex(p.Timestamp), p)
+	} else {
+		c.window.Push(p)
+	}
+
+	c.addToSums(p)
+
+	if c.maxVolume.Units() > 0 {
+		c.evictVolumeAbove(c.maxVolume)
+	}
+
+	// the check is never run more often than the size of the window to keep Add amortized O(1)
+	c.addedSinceCheck++
+	if c.driftCheckInterval > 0 && c.addedSinceCheck >= c.driftCheckInterval && c.addedSinceCheck >= c.window.Size() {
+		c.CheckDrift()
+	}
+}
+
+// ComputeAverage returns the average rounded to the nearest decimal. It returns 0 if the window has no volume.
+func (c *Calculator) ComputeAverage() (avg entity.Decimal, totalPoints int) {
+	return c.sums.Average(), c.window.Size()
+}
+
+// ComputeStdDev returns the volume weighted standard deviation of the window. It returns 0 if the window has no volume.
+func (c *Calculator) ComputeStdDev() entity.Decimal {
+	return c.sums.StdDev()
+}
+
+// ComputeOrderFlow returns the averages and volumes of the buy and sell points of the window.
+func (c *Calculator) ComputeOrderFlow() entity.OrderFlow {
+	return c.flow.OrderFlow()
+}
 
-		// substract the product value*volume of the poppedPoint from valueVolumeSum
-		c.valueVolumeSum -= poppedPoint.value.Value * poppedPoint.value.Volume
+// ComputePercentile returns the volume weighted percentile p (from 0 to 100) of the prices of the window:
+// the lowest price such that the points at or below it hold at least p % of the volume. The median is the percentile 50.
+// It returns false if the order statistics are not enabled or the window has no volume.
+func (c *Calculator) ComputePercentile(p float64) (entity.Decimal, bool) {
+	if c.prices == nil {
+		return entity.Decimal{}, false
 	}
 
-	c.stack.Push(p)
+	return c.prices.Quantile(p / 100)
+}
+
+// ComputeVenues returns the average, the volume and the share of the volume of the window of each venue, sorted by venue.
+func (c *Calculator) ComputeVenues() []entity.VenueShare {
+	shares := make([]entity.VenueShare, 0, len(c.venues))
+
+	for venue, sums := range c.venues {
+		share := entity.VenueShare{
+			Venue:   venue,
+			Average: sums.Average(),
+			Volume:  sums.totalVolume,
+		}
+
+		if c.sums.totalVolume.Units() > 0 {
+			share.Share = float64(sums.totalVolume.Units()) / float64(c.sums.totalVolume.Units())
+		}
+
+		shares = append(shares, share)
+	}
 
-	// recompute totalVolume and valueVolumeSum
-	c.totalVolume += p.Volume
-	c.valueVolumeSum += p.Value * p.Volume
+	sort.Slice(shares, func(i, j int) bool { return shares[i].Venue < shares[j].Venue })
 
-	log.GetLogger().Debugf("Total volume: %f ValueVolumeSum: %f Number of points: %d", c.totalVolume, c.valueVolumeSum, c.stack.Size())
+	return shares
 }
 
-func (c *Calculator) ComputeAverage() (avg float64, totalPoints int) {
-	return c.valueVolumeSum / c.totalVolume, c.stack.Size()
+// ComputeStats returns the number of points, the total volume and the timestamps of the oldest and newest points of the window.
+func (c *Calculator) ComputeStats() entity.WindowStats {
+	if c.window.Size() == 0 {
+		return entity.WindowStats{}
+	}
+
+	oldest, _ := c.window.Peek()
+
+	return entity.WindowStats{
+		TotalPoints: c.window.Size(),
+		Volume:      c.sums.totalVolume,
+		Start:       oldest.Timestamp,
+		End:         c.window.at(c.window.Size() - 1).Timestamp,
+	}
+}
+
+// CheckDrift recomputes the sums from the content of the window, compares them with the running sums
+// and resets the running sums to the recomputed values. It returns the observed drift.
+func (c *Calculator) CheckDrift() Drift {
+	var (
+		sums   vwapSums
+		flow   flowSums
+		venues map[string]vwapSums
+	)
+
+	c.window.Do(func(p entity.DataPoint) {
+		sums.Add(p)
+		flow.Add(p)
+
+		if len(p.Venue) > 0 {
+			venues = addVenue(venues, p)
+		}
+	})
+
+	c.lastDrift = Drift{
+		Volume:  c.sums.totalVolume.Sub(sums.totalVolume),
+		Average: c.sums.Average().Sub(sums.Average()),
+		StdDev:  c.sums.StdDev().Sub(sums.StdDev()),
+	}
+
+	c.sums = sums
+	c.flow = flow
+	c.venues = venues
+	c.addedSinceCheck = 0
+
+	if !c.lastDrift.Volume.IsZero() || !c.lastDrift.Average.IsZero() {
+		log.GetLogger().Warningf("drift observed. sums resynchronized: %+v", c.lastDrift)
+	} else {
+		log.GetLogger().Debugf("drift observed: %+v", c.lastDrift)
+	}
+
+	return c.lastDrift
+}
+
+// LastDrift returns the drift observed at the last check.
+func (c *Calculator) LastDrift() Drift {
+	return c.lastDrift
+}
+
+// insertIndex returns the position of a point with timestamp t in the window: after all the points not newer than t.
+// The window is searched from the newest point since the late points are usually only slightly late.
+func (c *Calculator) insertIndex(t time.Time) int {
+	i := c.window.Size()
+	for i > 0 && c.window.at(i-1).Timestamp.After(t) {
+		i--
+	}
+
+	return i
+}
+
+// evictOlderThan pops the points with a timestamp equal or before t.
+// The points of a time window are kept in timestamp order so the oldest point is always at the head of the window.
+func (c *Calculator) evictOlderThan(t time.Time) {
+	for p, ok := c.window.Peek(); ok && !p.Timestamp.After(t); p, ok = c.window.Peek() {
+		c.pop()
+	}
+}
+
+// evictVolumeAbove pops the oldest points until the window holds at most volume.
+// The last point to evict is split: only the excess volume is removed from it.
+func (c *Calculator) evictVolumeAbove(volume entity.Decimal) {
+	for excess := c.sums.totalVolume.Sub(volume); excess.Units() > 0; excess = c.sums.totalVolume.Sub(volume) {
+		oldest, _ := c.window.Peek()
+
+		if oldest.Volume.Cmp(excess) <= 0 {
+			c.pop()
+
+			continue
+		}
+
+		c.removeFromSums(oldest)
+		oldest.Volume = oldest.Volume.Sub(excess)
+		c.window.ReplaceOldest(oldest)
+		c.addToSums(oldest)
+	}
+}
+
+func (c *Calculator) pop() {
+	poppedPoint, _ := c.window.Pop()
+
+	// substract the poppedPoint from the sums
+	c.removeFromSums(poppedPoint)
+}
+
+func (c *Calculator) addToSums(p entity.DataPoint) {
+	c.sums.Add(p)
+	c.flow.Add(p)
+
+	if c.prices != nil {
+		c.prices.Add(p)
+	}
+
+	if len(p.Venue) > 0 {
+		c.venues = addVenue(c.venues, p)
+	}
+}
+
+func (c *Calculator) removeFromSums(p entity.DataPoint) {
+	c.sums.Remove(p)
+	c.flow.Remove(p)
+
+	if c.prices != nil {
+		c.prices.Remove(p)
+	}
+
+	if sums, found := c.venues[p.Venue]; found {
+		sums.Remove(p)
+
+		// the venue has no point left in the window
+		if sums.totalVolume.Units() <= 0 {
+			delete(c.venues, p.Venue)
+
+			return
+		}
+
+		c.venues[p.Venue] = sums
+	}
+}
+
+// addVenue adds p to the sums of its venue. The map is created with the first venue.
+func addVenue(venues map[string]vwapSums, p entity.DataPoint) map[string]vwapSums {
+	if venues == nil {
+		venues = make(map[string]vwapSums)
+	}
+
+	sums := venues[p.Venue]
+	sums.Add(p)
+	venues[p.Venue] = sums
+
+	return venues
 }
@@ -1,7 +1,9 @@
 package compute_test
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -9,28 +11,358 @@ import (
 	"github.com/tupyy/vwap/internal/entity"
 )
 
+func point(value, volume string) entity.DataPoint {
+	return entity.DataPoint{Value: entity.MustParseDecimal(value), Volume: entity.MustParseDecimal(volume)}
+}
+
+func timedPoint(value, volume string, ts time.Time) entity.DataPoint {
+	p := point(value, volume)
+	p.Timestamp = ts
+
+	return p
+}
+
 func TestCalculator(t *testing.T) {
 	calc := compute.NewCalculator(3)
 
-	calc.Add(entity.DataPoint{Value: 1, Volume: 1})
-	calc.Add(entity.DataPoint{Value: 2, Volume: 2})
+	calc.Add(point("1", "1"))
+	calc.Add(point("2", "2"))
 
 	// avg := (1*1 + 2*2) / 3 = 1.666
 	avg, totalPoints := calc.ComputeAverage()
 
-	assert.Equal(t, float64(5)/float64(3), avg, "expect avg = 1.6667")
+	assert.Equal(t, "1.66666667", avg.String(), "expect avg = 1.6667")
 	assert.Equal(t, 2, totalPoints, "expect 2 computation points")
 
-	calc.Add(entity.DataPoint{Value: 1, Volume: 1})
+	calc.Add(point("1", "1"))
 
 	avg, totalPoints = calc.ComputeAverage()
-	assert.Equal(t, float64(6)/float64(4), avg, "expected avg = 1.5")
+	assert.Equal(t, "1.5", avg.String(), "expected avg = 1.5")
 	assert.Equal(t, 3, totalPoints, "expect 3 computation points")
 
 	// avg = (2*2 + 1*1 + 2*2)/ 5
-	calc.Add(entity.DataPoint{Value: 2, Volume: 2})
+	calc.Add(point("2", "2"))
 
 	avg, totalPoints = calc.ComputeAverage()
-	assert.Equal(t, float64(9)/float64(5), avg, "expected avg = 1")
+	assert.Equal(t, "1.8", avg.String(), "expected avg = 1.8")
 	assert.Equal(t, 3, totalPoints, "expect 3 computation points")
 }
+
+func TestCalculatorExact(t *testing.T) {
+	calc := compute.NewCalculator(2)
+
+	// 1 satoshi at a high price: float64 cannot represent the sizes exactly
+	calc.Add(point("65432.12", "0.00000001"))
+	calc.Add(point("65432.13", "0.00000003"))
+
+	// (65432.12*1 + 65432.13*3) / 4 = 65432.1275
+	avg, _ := calc.ComputeAverage()
+	assert.Equal(t, entity.MustParseDecimal("65432.1275"), avg, "expect exact avg")
+
+	// no volume
+	empty := compute.NewCalculator(2)
+	avg, totalPoints := empty.ComputeAverage()
+	assert.True(t, avg.IsZero(), "expect 0 for an empty window")
+	assert.Equal(t, 0, totalPoints, "expect 0 computation points")
+}
+
+func TestTimeCalculator(t *testing.T) {
+	calc := compute.NewTimeCalculator(time.Minute)
+
+	now := time.Now()
+
+	calc.Add(timedPoint("1", "1", now))
+	calc.Add(timedPoint("2", "2", now.Add(30*time.Second)))
+
+	avg, totalPoints := calc.ComputeAverage()
+	assert.Equal(t, "1.66666667", avg.String(), "expect avg = 1.6667")
+	assert.Equal(t, 2, totalPoints, "expect 2 computation points")
+
+	// the first point falls off the window
+	calc.Add(timedPoint("4", "1", now.Add(time.Minute)))
+
+	avg, totalPoints = calc.ComputeAverage()
+	assert.Equal(t, "2.66666667", avg.String(), "expect avg = 2.6667")
+	assert.Equal(t, 2, totalPoints, "expect 2 computation points")
+
+	// a point older than the window is ignored
+	calc.Add(timedPoint("100", "1", now))
+
+	avg, totalPoints = calc.ComputeAverage()
+	assert.Equal(t, "2.66666667", avg.String(), "expect avg = 2.6667")
+	assert.Equal(t, 2, totalPoints, "expect 2 computation points")
+
+	// all the points fall off the window except the new one
+	calc.Add(timedPoint("3", "3", now.Add(5*time.Minute)))
+
+	avg, totalPoints = calc.ComputeAverage()
+	assert.Equal(t, "3", avg.String(), "expect avg = 3")
+	assert.Equal(t, 1, totalPoints, "expect 1 computation point")
+}
+
+func TestTimeCalculatorLatePoint(t *testing.T) {
+	calc := compute.NewTimeCalculator(time.Minute)
+
+	now := time.Now()
+
+	calc.Add(timedPoint("1", "1", now))
+	// late point still within the window
+	calc.Add(timedPoint("2", "1", now.Add(-50*time.Second)))
+
+	stats := calc.ComputeStats()
+	assert.Equal(t, now.Add(-50*time.Second), stats.Start, "the late point should be the oldest")
+	assert.Equal(t, now, stats.End)
+
+	// the late point falls off the window before the first one
+	calc.Add(timedPoint("4", "1", now.Add(55*time.Second)))
+
+	avg, totalPoints := calc.ComputeAverage()
+	assert.Equal(t, "2.5", avg.String(), "expect avg = (1 + 4) / 2")
+	assert.Equal(t, 2, totalPoints, "expect 2 computation points")
+}
+
+func TestVolumeCalculator(t *testing.T) {
+	calc := compute.NewVolumeCalculator(entity.DecimalFromInt(10))
+	calc.EnableOrderStatistics()
+
+	calc.Add(point("100", "4"))
+	calc.Add(point("200", "4"))
+
+	avg, totalPoints := calc.ComputeAverage()
+	assert.Equal(t, "150", avg.String())
+	assert.Equal(t, 2, totalPoints)
+
+	// 3 of the first point are evicted: 1 * 100 + 4 * 200 + 5 * 300
+	calc.Add(point("300", "5"))
+
+	avg, totalPoints = calc.ComputeAverage()
+	assert.Equal(t, "240", avg.String())
+	assert.Equal(t, 3, totalPoints)
+	assert.Equal(t, entity.DecimalFromInt(10), calc.ComputeStats().Volume)
+
+	// the point bigger than the window is split as well
+	calc.Add(point("400", "12"))
+
+	avg, totalPoints = calc.ComputeAverage()
+	assert.Equal(t, "400", avg.String())
+	assert.Equal(t, 1, totalPoints)
+
+	median, _ := calc.ComputePercentile(50)
+	assert.Equal(t, "400", median.String())
+
+	drift := calc.CheckDrift()
+	assert.True(t, drift.Volume.IsZero(), "the split points should be kept in the sums")
+}
+
+func TestCalculatorDrift(t *testing.T) {
+	calc := compute.NewCalculator(10)
+	calc.SetDriftCheckInterval(0)
+
+	// mix very large and very small trades which would make float64 running sums drift
+	for i := 0; i < 1_000_000; i++ {
+		if i%2 == 0 {
+			calc.Add(point("65432.1", "1234.5678"))
+		} else {
+			calc.Add(point("0.00001", "0.00000001"))
+		}
+	}
+
+	avg, totalPoints := calc.ComputeAverage()
+	assert.Equal(t, 10, totalPoints, "expect 10 computation points")
+
+	fresh := compute.NewCalculator(10)
+	for i := 0; i < 5; i++ {
+		fresh.Add(point("65432.1", "1234.5678"))
+		fresh.Add(point("0.00001", "0.00000001"))
+	}
+
+	expected, _ := fresh.ComputeAverage()
+	assert.Equal(t, expected, avg, "average should not drift")
+
+	drift := calc.CheckDrift()
+	assert.True(t, drift.Volume.IsZero(), "volume should not drift")
+	assert.True(t, drift.Average.IsZero(), "average should not drift")
+	assert.Equal(t, drift, calc.LastDrift())
+}
+
+func BenchmarkCalculatorAdd(b *testing.B) {
+	for _, size := range []int{200, 100_000, 10_000_000} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			calc := compute.NewCalculator(size)
+			for i := 0; i < size; i++ {
+				calc.Add(point("1", "1"))
+			}
+
+			// reset the drift check counter so the O(n) check does not run while measuring
+			calc.CheckDrift()
+
+			p := point("2", "1")
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				calc.Add(p)
+			}
+		})
+	}
+}
+
+func BenchmarkCalculatorAddOrderStatistics(b *testing.B) {
+	for _, size := range []int{200, 100_000} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			calc := compute.NewCalculator(size)
+			calc.EnableOrderStatistics()
+
+			for i := 0; i < size; i++ {
+				calc.Add(point(fmt.Sprintf("%d", i%1000), "1"))
+			}
+
+			calc.CheckDrift()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				calc.Add(entity.DataPoint{Value: entity.DecimalFromInt(int64(i % 1000)), Volume: entity.DecimalFromInt(1)})
+			}
+		})
+	}
+}
+
+func TestCalculatorPercentiles(t *testing.T) {
+	calc := compute.NewCalculator(3)
+
+	_, found := calc.ComputePercentile(50)
+	assert.False(t, found, "order statistics are not enabled")
+
+	calc.Add(point("100", "1"))
+	calc.EnableOrderStatistics()
+
+	calc.Add(point("1000", "1"))
+	calc.Add(point("101", "2"))
+
+	median, _ := calc.ComputePercentile(50)
+	assert.Equal(t, "101", median.String(), "the median should not be moved by the extreme price")
+
+	// 100 falls off the window
+	calc.Add(point("102", "1"))
+
+	p5, _ := calc.ComputePercentile(5)
+	assert.Equal(t, "101", p5.String())
+
+	p95, _ := calc.ComputePercentile(95)
+	assert.Equal(t, "1000", p95.String())
+}
+
+func TestCalculatorStdDev(t *testing.T) {
+	calc := compute.NewCalculator(3)
+
+	calc.Add(point("10", "1"))
+
+	assert.True(t, calc.ComputeStdDev().IsZero(), "expect no deviation with one point")
+
+	calc.Add(point("20", "1"))
+	calc.Add(point("40", "2"))
+
+	// avg = (10 + 20 + 80) / 4 = 27.5
+	// variance = (100 + 400 + 3200) / 4 - 27.5^2 = 168.75
+	assert.InDelta(t, 12.99038106, calc.ComputeStdDev().Float64(), 1e-8)
+
+	// the first point falls off: avg = (20 + 80 + 40) / 4 = 35, variance = (400 + 3200 + 1600) / 4 - 35^2 = 75
+	calc.Add(point("40", "1"))
+	assert.InDelta(t, 8.66025404, calc.ComputeStdDev().Float64(), 1e-8)
+
+	drift := calc.CheckDrift()
+	assert.True(t, drift.StdDev.IsZero(), "standard deviation should not drift")
+}
+
+func TestCalculatorStdDevHighPrice(t *testing.T) {
+	calc := compute.NewCalculator(3)
+
+	// a sub-cent spread on a high price cancels out when the variance is computed in float64
+	calc.Add(point("65432.12", "0.3"))
+	calc.Add(point("65432.12", "0.3"))
+	calc.Add(point("65432.13", "0.3"))
+
+	// avg = 65432.12333333, variance = 0.01^2 * 2 / 9
+	assert.Equal(t, "0.00471405", calc.ComputeStdDev().String())
+}
+
+func TestCalculatorOrderFlow(t *testing.T) {
+	calc := compute.NewCalculator(3)
+
+	buy := func(value, volume string) entity.DataPoint {
+		p := point(value, volume)
+		p.Side = entity.Buy
+
+		return p
+	}
+
+	sell := func(value, volume string) entity.DataPoint {
+		p := point(value, volume)
+		p.Side = entity.Sell
+
+		return p
+	}
+
+	calc.Add(sell("9", "4"))
+	calc.Add(buy("10", "1"))
+	calc.Add(buy("12", "1"))
+
+	assert.Equal(t, entity.OrderFlow{
+		BuyAverage:  entity.DecimalFromInt(11),
+		SellAverage: entity.DecimalFromInt(9),
+		BuyVolume:   entity.DecimalFromInt(2),
+		SellVolume:  entity.DecimalFromInt(4),
+		Imbalance:   float64(-2) / float64(6),
+	}, calc.ComputeOrderFlow())
+
+	// the sell falls off the window
+	calc.Add(buy("14", "2"))
+
+	flow := calc.ComputeOrderFlow()
+	assert.Equal(t, "12.5", flow.BuyAverage.String())
+	assert.True(t, flow.SellAverage.IsZero(), "expect no sell")
+	assert.Equal(t, float64(1), flow.Imbalance, "expect only buys")
+}
+
+func TestCalculatorVenues(t *testing.T) {
+	calc := compute.NewVolumeCalculator(entity.DecimalFromInt(4))
+
+	venue := func(value, volume, venue string) entity.DataPoint {
+		p := point(value, volume)
+		p.Venue = venue
+
+		return p
+	}
+
+	calc.Add(venue("10", "2", "a"))
+	calc.Add(venue("12", "1", "b"))
+	calc.Add(venue("14", "1", "b"))
+
+	assert.Equal(t, []entity.VenueShare{
+		{Venue: "a", Average: entity.DecimalFromInt(10), Volume: entity.DecimalFromInt(2), Share: 0.5},
+		{Venue: "b", Average: entity.DecimalFromInt(13), Volume: entity.DecimalFromInt(2), Share: 0.5},
+	}, calc.ComputeVenues())
+
+	// the point of a is split: only 1 of its volume is left
+	calc.Add(venue("16", "1", "b"))
+
+	shares := calc.ComputeVenues()
+	assert.Equal(t, entity.DecimalFromInt(1), shares[0].Volume)
+	assert.Equal(t, 0.25, shares[0].Share)
+	assert.Equal(t, 0.75, shares[1].Share)
+
+	// a falls off the window
+	calc.Add(venue("18", "1", "b"))
+
+	shares = calc.ComputeVenues()
+	assert.Len(t, shares, 1)
+	assert.Equal(t, "b", shares[0].Venue)
+	assert.Equal(t, float64(1), shares[0].Share)
+
+	// the venue sums are recomputed by the drift check
+	calc.CheckDrift()
+	assert.Equal(t, shares, calc.ComputeVenues())
+}
@@ -2,11 +2,16 @@
 package conf
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/tupyy/vwap/internal/entity"
 	"github.com/tupyy/vwap/internal/log"
 )
 
@@ -24,6 +29,159 @@ type Conf struct {
 	TradingPairs  []string
 	MaxDataPoints int64
 	OutputFile    string
+	// AuditFile -- if set, the tickers dropped by the filters are written to this file.
+	AuditFile string
+	// Pairs holds the configuration of each trading pair. The key is the product id.
+	Pairs map[string]PairConf
+	// SyntheticPairs -- pairs whose vwap is implied by the product of the vwaps of trading pairs.
+	SyntheticPairs []SyntheticPair
+	// ArbitrageCycles -- cycles of currencies monitored for triangular arbitrage.
+	ArbitrageCycles []ArbitrageCycle
+	// ConsolidatedPairs -- assets traded on several venues whose trades are merged into one window.
+	ConsolidatedPairs []ConsolidatedPair
+	// Checkpoint -- where and how often the state of the calculators is saved to be restored on restart.
+	Checkpoint Checkpoint
+}
+
+// ConsolidatedPair defines an asset whose trades on several venues are merged into one vwap window.
+type ConsolidatedPair struct {
+	// Symbol -- canonical symbol of the asset
+	Symbol string
+	// Window -- count or volume window of the merged trades
+	Window Horizon
+	Venues []Venue
+}
+
+// Venue is the trade stream of a venue of a consolidated pair.
+type Venue struct {
+	// Name -- name of the venue
+	Name string
+	// Endpoint -- ws address of the feed of the venue. The feed speaks the coinbase protocol.
+	Endpoint string
+	// ProductID -- product whose tickers are the trades of the venue. All the venues trade the same base and quote currency.
+	ProductID string
+	// Weight -- the volume of the trades of the venue is multiplied by Weight. Zero means 1.
+	Weight entity.Decimal
+	// Excluded -- if true, the trades of the venue are not merged
+	Excluded bool
+}
+
+// Default checkpoint interval and max age.
+const (
+	DefaultCheckpointInterval = time.Minute
+	DefaultCheckpointMaxAge   = 5 * time.Minute
+)
+
+// Checkpoint defines the local file where the state of the calculators is saved.
+type Checkpoint struct {
+	// File -- path of the checkpoint. Empty disables the checkpoints.
+	File string
+	// Interval -- period of the checkpoints. The state is saved on shutdown as well.
+	Interval time.Duration
+	// MaxAge -- on startup, the checkpoint is restored only if it was saved less than MaxAge ago.
+	MaxAge time.Duration
+}
+
+// ArbitrageCycle defines a cycle of currencies (e.g. USD, BTC and ETH) monitored in both directions for triangular arbitrage.
+type ArbitrageCycle struct {
+	// Currencies -- the currencies of the cycle. Consecutive currencies must be traded by a trading pair.
+	Currencies []string
+	// Horizon -- name of the vwap horizon of the pairs used as prices
+	Horizon string
+	// FeeBps -- fee paid on each trade in basis points
+	FeeBps float64
+	// MinEdgeBps -- minimum profit of the cycle after fees in basis points to raise an alert
+	MinEdgeBps float64
+}
+
+// SyntheticPair defines a pair implied by the product of the vwaps of its legs (e.g. ETH-USD via ETH-BTC and BTC-USD).
+type SyntheticPair struct {
+	// ProductID -- id of the synthetic pair. If it is a trading pair as well, the implied vwap is compared to its vwap.
+	ProductID string
+	// Legs -- ids of the trading pairs whose vwaps are multiplied
+	Legs []string
+	// Horizon -- name of the vwap horizon of the legs
+	Horizon string
+}
+
+// PairConf holds the configuration of one trading pair.
+type PairConf struct {
+	// Horizons -- windows over which the average is computed. A pair has at least one horizon.
+	// By default, the pair has one horizon over the last MaxDataPoints points.
+	Horizons []Horizon
+	// QuoteIncrement -- if set, the average is rounded to a multiple of the quote increment of the product.
+	QuoteIncrement entity.Decimal
+	// BandMultipliers -- number of standard deviations of the bands reported around the average.
+	BandMultipliers []float64
+	// Median -- if true, the volume weighted median of the count, time and volume horizons is reported.
+	Median bool
+	// Percentiles -- volume weighted percentiles (from 0 to 100) of the count, time and volume horizons reported.
+	Percentiles []float64
+	// CandleIntervals -- intervals of the OHLCV bars built from the tickers.
+	CandleIntervals []time.Duration
+	// MarkDegraded -- if true, the vwap and twap results are marked degraded after a gap in the trade ids until the window has turned over.
+	MarkDegraded bool
+	// ReorderSize -- maximum number of messages held to be released in sequence order. Zero means no limit.
+	ReorderSize int
+	// ReorderDelay -- maximum time a message is held to be released in sequence order. Zero means no limit.
+	// The messages are not reordered if both ReorderSize and ReorderDelay are zero.
+	ReorderDelay time.Duration
+	// Filters -- tickers dropped before being processed. It defaults to the global filters.
+	Filters Filters
+	// WarmUp -- the vwap and twap results are marked warming up until their window meets these thresholds. It defaults to the global warm-up.
+	WarmUp WarmUp
+	// Indicators -- indicators computed from the tickers of the pair along with the averages.
+	Indicators []Indicator
+}
+
+// Indicator selects a registered indicator by its type.
+type Indicator struct {
+	// Type -- type of the indicator (e.g. "ema" or "rsi")
+	Type string
+	// Name -- name of the indicator reported in the output. It defaults to the type.
+	Name string
+	// Params -- json object of the parameters of the indicator. They are decoded by the indicator.
+	Params json.RawMessage
+}
+
+// WarmUp holds the thresholds a window must meet before its average is considered a reliable benchmark. Zero thresholds are ignored.
+type WarmUp struct {
+	// MinPoints -- minimum number of points
+	MinPoints int
+	// MinVolume -- minimum total volume
+	MinVolume entity.Decimal
+	// MinSpan -- minimum time between the oldest and the newest point
+	MinSpan time.Duration
+}
+
+// Filters defines which tickers are dropped before being processed.
+type Filters struct {
+	// MinVolume -- the tickers whose size is lower than MinVolume are dropped (e.g. dust trades). Zero keeps all the tickers.
+	MinVolume entity.Decimal
+	// OutlierMADs -- the trades more than OutlierMADs robust deviations from the median price of the last trades are dropped. Zero disables it.
+	OutlierMADs float64
+	// OutlierPercent -- the trades more than OutlierPercent % from the current vwap of the pair are dropped. Zero disables it.
+	OutlierPercent float64
+	// OutlierWindow -- number of the last trades used as reference by the outlier filter. Zero means the default.
+	OutlierWindow int
+}
+
+// Horizon defines a named window. Exactly one of MaxDataPoints, WindowDuration, MaxVolume, Session and HalfLife is set.
+type Horizon struct {
+	// Name -- name of the horizon. It defaults to the value of the window (e.g. "200", "5m" or "@daily").
+	Name string
+	// MaxDataPoints -- the average is computed over the last MaxDataPoints points.
+	MaxDataPoints int64
+	// WindowDuration -- the average is computed over the points of the last WindowDuration.
+	WindowDuration time.Duration
+	// MaxVolume -- the average is computed over the last MaxVolume traded. The oldest point is split to cover exactly MaxVolume.
+	MaxVolume entity.Decimal
+	// Session -- cron-like schedule of the session anchors in UTC. The average is computed over all the points since the last anchor.
+	Session string
+	// HalfLife -- the average is exponentially decaying: the weight of a point is halved every HalfLife.
+	HalfLife time.Duration
+	// Method -- kind of average computed over the window. It defaults to vwap. twap requires a WindowDuration.
+	Method entity.Method
 }
 
 func init() {
@@ -32,9 +190,12 @@ func init() {
 	flag.StringVar(&logLevel, "log_level", "info", "log level")
 	flag.StringVar(&confFile, "config", "", "path of the configuration file")
 	flag.StringVar(&outputFile, "output", "", "path of the output file")
-	flag.Int64Var(&maxDataPoints, "max_data_points", 200, "maximum number of data points used to compute the average")
+	flag.Int64Var(&maxDataPoints, "max_data_points", defaultMaxDataPoints, "maximum number of data points used to compute the average")
 }
 
+// defaultMaxDataPoints is the size of the default horizon of the pairs when max_data_points is not set.
+const defaultMaxDataPoints = 200
+
 func Get() Conf {
 	flag.Parse()
 
@@ -45,14 +206,19 @@ func Get() Conf {
 			panic(err)
 		}
 
-		conf := parseConfFile(content)
+		conf, err := parseConfFile(content)
+		if err != nil {
+			panic(err)
+		}
 
 		if conf.MaxDataPoints == 0 {
 			log.GetLogger().Warningf("cannot set max data points to 0. Default to 200.")
 
-			conf.MaxDataPoints = 200
+			conf.MaxDataPoints = defaultMaxDataPoints
 		}
 
+		setDefaultHorizons(&conf)
+
 		return conf
 	}
 
@@ -71,19 +237,50 @@ func Get() Conf {
 
 	conf.TradingPairs = append(conf.TradingPairs, strings.Split(pairs, ",")...)
 
+	if maxDataPoints < 0 {
+		log.GetLogger().Errorf("max_data_points must be positive.")
+
+		os.Exit(1)
+	}
+
 	if maxDataPoints == 0 {
 		log.GetLogger().Warningf("cannot set max data points to 0. Default to 200.")
 
-		maxDataPoints = 200
+		maxDataPoints = defaultMaxDataPoints
 	}
 
 	conf.MaxDataPoints = maxDataPoints
 
+	setDefaultHorizons(&conf)
+
 	log.SetLogLevel(parseLogLevel(logLevel))
 
 	return conf
 }
 
+// setDefaultHorizons sets a horizon over the last MaxDataPoints points to every trading pair without horizon.
+func setDefaultHorizons(conf *Conf) {
+	if conf.Pairs == nil {
+		conf.Pairs = make(map[string]PairConf, len(conf.TradingPairs))
+	}
+
+	for _, productID := range conf.TradingPairs {
+		pairConf := conf.Pairs[productID]
+
+		if len(pairConf.Horizons) == 0 {
+			pairConf.Horizons = []Horizon{
+				{
+					Name:          strconv.FormatInt(conf.MaxDataPoints, 10),
+					MaxDataPoints: conf.MaxDataPoints,
+					Method:        entity.VWAP,
+				},
+			}
+		}
+
+		conf.Pairs[productID] = pairConf
+	}
+}
+
 func parseLogLevel(l string) log.Level {
 	switch strings.ToLower(l) {
 	case "trace":
@@ -100,26 +297,643 @@ func parseLogLevel(l string) log.Level {
 }
 
 // nolint: tagliatelle
-func parseConfFile(content []byte) Conf {
-	confFile := struct {
-		Endpoint      string   `json:"endpoint"`
-		TradingPairs  []string `json:"trading_pairs"`
-		LogLevel      string   `json:"log_level,omitempty"`
-		MaxDataPoints int64    `json:"max_data_points,omitempty"`
-		OutputFile    string   `json:"output_file,omitempty"`
-	}{}
+type configFile struct {
+	Endpoint      string              `json:"endpoint"`
+	TradingPairs  []string            `json:"trading_pairs"`
+	LogLevel      string              `json:"log_level,omitempty"`
+	MaxDataPoints int64               `json:"max_data_points,omitempty"`
+	OutputFile    string              `json:"output_file,omitempty"`
+	AuditFile     string              `json:"audit_file,omitempty"`
+	Filters       *filtersFile        `json:"filters,omitempty"`
+	WarmUp        *warmUpFile         `json:"warm_up,omitempty"`
+	Pairs         map[string]pairFile `json:"pairs,omitempty"`
+	Synthetic     []syntheticFile     `json:"synthetic_pairs,omitempty"`
+	Arbitrage     []arbitrageFile     `json:"arbitrage_cycles,omitempty"`
+	Checkpoint    *checkpointFile     `json:"checkpoint,omitempty"`
+	Consolidated  []consolidatedFile  `json:"consolidated_pairs,omitempty"`
+}
+
+// nolint: tagliatelle
+type consolidatedFile struct {
+	Symbol string      `json:"symbol"`
+	Window horizonFile `json:"window"`
+	Venues []venueFile `json:"venues"`
+}
+
+// nolint: tagliatelle
+type venueFile struct {
+	Name      string         `json:"name"`
+	Endpoint  string         `json:"endpoint"`
+	ProductID string         `json:"product_id"`
+	Weight    entity.Decimal `json:"weight,omitempty"`
+	Excluded  bool           `json:"excluded,omitempty"`
+}
+
+// nolint: tagliatelle
+type checkpointFile struct {
+	File     string `json:"file"`
+	Interval string `json:"interval,omitempty"`
+	MaxAge   string `json:"max_age,omitempty"`
+}
+
+// nolint: tagliatelle
+type arbitrageFile struct {
+	Currencies []string `json:"currencies"`
+	Horizon    string   `json:"horizon"`
+	FeeBps     float64  `json:"fee_bps,omitempty"`
+	MinEdgeBps float64  `json:"min_edge_bps,omitempty"`
+}
+
+// nolint: tagliatelle
+type syntheticFile struct {
+	ProductID string   `json:"product_id"`
+	Legs      []string `json:"legs"`
+	Horizon   string   `json:"horizon"`
+}
 
-	// unmarshal the content into confFile
-	if err := json.Unmarshal(content, &confFile); err != nil {
-		panic(err)
+// nolint: tagliatelle
+type pairFile struct {
+	MaxDataPoints   int64           `json:"max_data_points,omitempty"`
+	WindowDuration  string          `json:"window_duration,omitempty"`
+	QuoteIncrement  entity.Decimal  `json:"quote_increment,omitempty"`
+	Horizons        []horizonFile   `json:"horizons,omitempty"`
+	BandMultipliers []float64       `json:"band_multipliers,omitempty"`
+	Median          bool            `json:"median,omitempty"`
+	Percentiles     []float64       `json:"percentiles,omitempty"`
+	CandleIntervals []string        `json:"candle_intervals,omitempty"`
+	MarkDegraded    bool            `json:"mark_degraded,omitempty"`
+	ReorderSize     int             `json:"reorder_size,omitempty"`
+	ReorderDelay    string          `json:"reorder_delay,omitempty"`
+	Filters         *filtersFile    `json:"filters,omitempty"`
+	WarmUp          *warmUpFile     `json:"warm_up,omitempty"`
+	Indicators      []indicatorFile `json:"indicators,omitempty"`
+}
+
+// nolint: tagliatelle
+type indicatorFile struct {
+	Type   string          `json:"type"`
+	Name   string          `json:"name,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// nolint: tagliatelle
+type warmUpFile struct {
+	MinPoints int            `json:"min_points,omitempty"`
+	MinVolume entity.Decimal `json:"min_volume,omitempty"`
+	MinSpan   string         `json:"min_span,omitempty"`
+}
+
+// nolint: tagliatelle
+type filtersFile struct {
+	MinVolume      entity.Decimal `json:"min_volume,omitempty"`
+	OutlierMADs    float64        `json:"outlier_mads,omitempty"`
+	OutlierPercent float64        `json:"outlier_percent,omitempty"`
+	OutlierWindow  int            `json:"outlier_window,omitempty"`
+}
+
+// parseConfFile parses the json configuration. Unknown keys are rejected so a typo does not silently fall back to a default.
+func parseConfFile(content []byte) (Conf, error) {
+	var file configFile
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&file); err != nil {
+		return Conf{}, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	log.SetLogLevel(parseLogLevel(confFile.LogLevel))
+	log.SetLogLevel(parseLogLevel(file.LogLevel))
+
+	// a negative size would give an unbounded window. Zero means the default size.
+	if file.MaxDataPoints < 0 {
+		return Conf{}, fmt.Errorf("max_data_points must be positive")
+	}
+
+	// the global filters are the default filters of the pairs
+	var defaultFilters Filters
+
+	if file.Filters != nil {
+		filters, err := file.Filters.parse()
+		if err != nil {
+			return Conf{}, fmt.Errorf("filters: %w", err)
+		}
+
+		defaultFilters = filters
+	}
+
+	// the global warm-up is the default warm-up of the pairs
+	var defaultWarmUp WarmUp
+
+	if file.WarmUp != nil {
+		warmUp, err := file.WarmUp.parse()
+		if err != nil {
+			return Conf{}, fmt.Errorf("warm_up: %w", err)
+		}
+
+		defaultWarmUp = warmUp
+	}
+
+	pairs := make(map[string]PairConf, len(file.Pairs))
+	for productID, p := range file.Pairs {
+		if !contains(file.TradingPairs, productID) {
+			return Conf{}, fmt.Errorf("pair %s is not one of the trading pairs", productID)
+		}
+
+		pairConf, err := p.parse(defaultFilters, defaultWarmUp)
+		if err != nil {
+			return Conf{}, fmt.Errorf("pair %s: %w", productID, err)
+		}
+
+		pairs[productID] = pairConf
+	}
+
+	// the pairs without section get the default filters and warm-up
+	for _, productID := range file.TradingPairs {
+		if _, found := pairs[productID]; !found {
+			pairs[productID] = PairConf{Filters: defaultFilters, WarmUp: defaultWarmUp}
+		}
+	}
+
+	synthetics := make([]SyntheticPair, 0, len(file.Synthetic))
+
+	for _, s := range file.Synthetic {
+		if len(s.ProductID) == 0 || len(s.Horizon) == 0 || len(s.Legs) < 2 {
+			return Conf{}, fmt.Errorf("synthetic pair %q: product_id, horizon and at least two legs are required", s.ProductID)
+		}
+
+		for _, leg := range s.Legs {
+			if !contains(file.TradingPairs, leg) {
+				return Conf{}, fmt.Errorf("synthetic pair %s: leg %s is not one of the trading pairs", s.ProductID, leg)
+			}
+
+			if !hasVWAPHorizon(pairs[leg], s.Horizon, file.MaxDataPoints) {
+				return Conf{}, fmt.Errorf("synthetic pair %s: leg %s has no vwap horizon %q", s.ProductID, leg, s.Horizon)
+			}
+		}
+
+		if err := checkLegs(s.ProductID, s.Legs); err != nil {
+			return Conf{}, fmt.Errorf("synthetic pair %s: %w", s.ProductID, err)
+		}
+
+		synthetics = append(synthetics, SyntheticPair(s))
+	}
+
+	cycles := make([]ArbitrageCycle, 0, len(file.Arbitrage))
+
+	for _, c := range file.Arbitrage {
+		if len(c.Currencies) < 3 || len(c.Horizon) == 0 {
+			return Conf{}, fmt.Errorf("arbitrage cycle %v: horizon and at least three currencies are required", c.Currencies)
+		}
+
+		if c.FeeBps < 0 {
+			return Conf{}, fmt.Errorf("arbitrage cycle %v: fee_bps must not be negative", c.Currencies)
+		}
+
+		cycles = append(cycles, ArbitrageCycle(c))
+	}
+
+	consolidated := make([]ConsolidatedPair, 0, len(file.Consolidated))
+	// venueEndpoints -- endpoint of each venue: a venue is one feed shared by all the consolidated pairs
+	venueEndpoints := make(map[string]string)
+
+	for _, c := range file.Consolidated {
+		pair, err := c.parse()
+		if err != nil {
+			return Conf{}, fmt.Errorf("consolidated pair %q: %w", c.Symbol, err)
+		}
+
+		for _, v := range pair.Venues {
+			if endpoint, found := venueEndpoints[v.Name]; found && endpoint != v.Endpoint {
+				return Conf{}, fmt.Errorf("consolidated pair %q: venue %s has another endpoint in another consolidated pair", c.Symbol, v.Name)
+			}
+
+			venueEndpoints[v.Name] = v.Endpoint
+		}
+
+		consolidated = append(consolidated, pair)
+	}
+
+	var checkpoint Checkpoint
+
+	if file.Checkpoint != nil {
+		c, err := file.Checkpoint.parse()
+		if err != nil {
+			return Conf{}, fmt.Errorf("checkpoint: %w", err)
+		}
+
+		checkpoint = c
+	}
 
 	return Conf{
-		Endpoint:      confFile.Endpoint,
-		TradingPairs:  confFile.TradingPairs,
-		MaxDataPoints: confFile.MaxDataPoints,
-		OutputFile:    confFile.OutputFile,
+		Endpoint:          file.Endpoint,
+		TradingPairs:      file.TradingPairs,
+		MaxDataPoints:     file.MaxDataPoints,
+		OutputFile:        file.OutputFile,
+		AuditFile:         file.AuditFile,
+		Pairs:             pairs,
+		SyntheticPairs:    synthetics,
+		ArbitrageCycles:   cycles,
+		ConsolidatedPairs: consolidated,
+		Checkpoint:        checkpoint,
+	}, nil
+}
+
+// checkLegs returns an error unless the legs chain from the base currency to the quote currency of the product:
+// the base of the first leg is the base of the product, the quote of each leg is the base of the next one
+// and the quote of the last leg is the quote of the product (e.g. ETH-USD via ETH-BTC and BTC-USD).
+func checkLegs(productID string, legs []string) error {
+	base, quote, ok := splitProductID(productID)
+	if !ok {
+		return fmt.Errorf("product id must be BASE-QUOTE")
 	}
+
+	currency := base
+
+	for _, leg := range legs {
+		legBase, legQuote, ok := splitProductID(leg)
+		if !ok {
+			return fmt.Errorf("leg %s: product id must be BASE-QUOTE", leg)
+		}
+
+		if legBase != currency {
+			return fmt.Errorf("leg %s does not start from %s", leg, currency)
+		}
+
+		currency = legQuote
+	}
+
+	if currency != quote {
+		return fmt.Errorf("legs end in %s instead of %s", currency, quote)
+	}
+
+	return nil
+}
+
+// splitProductID returns the base and quote currencies of a product id (e.g. BTC and USD for BTC-USD).
+func splitProductID(productID string) (base, quote string, ok bool) {
+	i := strings.IndexByte(productID, '-')
+	if i <= 0 || i == len(productID)-1 {
+		return "", "", false
+	}
+
+	return productID[:i], productID[i+1:], true
+}
+
+// hasVWAPHorizon returns true if the pair has a vwap horizon named name.
+// A pair without horizon gets the default horizon over the last maxDataPoints points (see setDefaultHorizons).
+func hasVWAPHorizon(p PairConf, name string, maxDataPoints int64) bool {
+	if len(p.Horizons) == 0 {
+		if maxDataPoints == 0 {
+			maxDataPoints = defaultMaxDataPoints
+		}
+
+		return name == strconv.FormatInt(maxDataPoints, 10)
+	}
+
+	for _, h := range p.Horizons {
+		if h.Method == entity.VWAP && h.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p pairFile) parse(defaultFilters Filters, defaultWarmUp WarmUp) (PairConf, error) {
+	pairConf := PairConf{
+		QuoteIncrement:  p.QuoteIncrement,
+		BandMultipliers: p.BandMultipliers,
+		Median:          p.Median,
+		Percentiles:     p.Percentiles,
+		MarkDegraded:    p.MarkDegraded,
+		ReorderSize:     p.ReorderSize,
+		Filters:         defaultFilters,
+		WarmUp:          defaultWarmUp,
+		Horizons:        make([]Horizon, 0, len(p.Horizons)+1),
+	}
+
+	if p.Filters != nil {
+		filters, err := p.Filters.parse()
+		if err != nil {
+			return PairConf{}, fmt.Errorf("filters: %w", err)
+		}
+
+		pairConf.Filters = filters
+	}
+
+	if p.WarmUp != nil {
+		warmUp, err := p.WarmUp.parse()
+		if err != nil {
+			return PairConf{}, fmt.Errorf("warm_up: %w", err)
+		}
+
+		pairConf.WarmUp = warmUp
+	}
+
+	// max_data_points and window_duration are shorthands for a single horizon
+	if p.MaxDataPoints < 0 {
+		return PairConf{}, fmt.Errorf("max_data_points must be positive")
+	}
+
+	if p.MaxDataPoints > 0 {
+		p.Horizons = append(p.Horizons, horizonFile{MaxDataPoints: p.MaxDataPoints})
+	}
+
+	if len(p.WindowDuration) > 0 {
+		p.Horizons = append(p.Horizons, horizonFile{WindowDuration: p.WindowDuration})
+	}
+
+	for _, h := range p.Horizons {
+		horizon, err := h.parse()
+		if err != nil {
+			return PairConf{}, err
+		}
+
+		// the results, the checkpoints and the queries identify a horizon by its name
+		for _, other := range pairConf.Horizons {
+			if other.Name == horizon.Name {
+				return PairConf{}, fmt.Errorf("horizon %q defined twice", horizon.Name)
+			}
+		}
+
+		pairConf.Horizons = append(pairConf.Horizons, horizon)
+	}
+
+	for _, i := range p.Indicators {
+		if len(i.Type) == 0 {
+			return PairConf{}, fmt.Errorf("indicator %q: type is required", i.Name)
+		}
+
+		pairConf.Indicators = append(pairConf.Indicators, Indicator(i))
+	}
+
+	if len(p.ReorderDelay) > 0 {
+		delay, err := time.ParseDuration(p.ReorderDelay)
+		if err != nil || delay < 0 {
+			return PairConf{}, fmt.Errorf("invalid reorder delay %q", p.ReorderDelay)
+		}
+
+		pairConf.ReorderDelay = delay
+	}
+
+	for _, pc := range p.Percentiles {
+		if pc < 0 || pc > 100 {
+			return PairConf{}, fmt.Errorf("percentile %g out of range [0-100]", pc)
+		}
+	}
+
+	if p.ReorderSize < 0 {
+		return PairConf{}, fmt.Errorf("reorder size must not be negative")
+	}
+
+	for _, i := range p.CandleIntervals {
+		interval, err := time.ParseDuration(i)
+		if err != nil || interval <= 0 {
+			return PairConf{}, fmt.Errorf("invalid candle interval %q", i)
+		}
+
+		pairConf.CandleIntervals = append(pairConf.CandleIntervals, interval)
+	}
+
+	return pairConf, nil
+}
+
+func (f filtersFile) parse() (Filters, error) {
+	if f.OutlierMADs < 0 || f.OutlierPercent < 0 || f.OutlierWindow < 0 {
+		return Filters{}, fmt.Errorf("outlier_mads, outlier_percent and outlier_window must not be negative")
+	}
+
+	return Filters{
+		MinVolume:      f.MinVolume,
+		OutlierMADs:    f.OutlierMADs,
+		OutlierPercent: f.OutlierPercent,
+		OutlierWindow:  f.OutlierWindow,
+	}, nil
+}
+
+func (w warmUpFile) parse() (WarmUp, error) {
+	if w.MinPoints < 0 || w.MinVolume.Cmp(entity.Decimal{}) < 0 {
+		return WarmUp{}, fmt.Errorf("min_points and min_volume must not be negative")
+	}
+
+	warmUp := WarmUp{
+		MinPoints: w.MinPoints,
+		MinVolume: w.MinVolume,
+	}
+
+	if len(w.MinSpan) > 0 {
+		span, err := time.ParseDuration(w.MinSpan)
+		if err != nil || span < 0 {
+			return WarmUp{}, fmt.Errorf("invalid min_span %q", w.MinSpan)
+		}
+
+		warmUp.MinSpan = span
+	}
+
+	return warmUp, nil
+}
+
+func (c consolidatedFile) parse() (ConsolidatedPair, error) {
+	if len(c.Symbol) == 0 || len(c.Venues) < 2 {
+		return ConsolidatedPair{}, fmt.Errorf("symbol and at least two venues are required")
+	}
+
+	window, err := c.Window.parse()
+	if err != nil {
+		return ConsolidatedPair{}, err
+	}
+
+	// the trades of the venues are merged in the order they are received, which is not the order of their timestamps
+	if window.Method != entity.VWAP || (window.MaxDataPoints == 0 && window.MaxVolume.IsZero()) {
+		return ConsolidatedPair{}, fmt.Errorf("window must be a vwap over max_data_points or max_volume")
+	}
+
+	pair := ConsolidatedPair{
+		Symbol: c.Symbol,
+		Window: window,
+		Venues: make([]Venue, 0, len(c.Venues)),
+	}
+
+	var base, quote string
+
+	names := make(map[string]bool, len(c.Venues))
+
+	for i, v := range c.Venues {
+		if len(v.Name) == 0 || len(v.Endpoint) == 0 {
+			return ConsolidatedPair{}, fmt.Errorf("venue of %s: name and endpoint are required", v.ProductID)
+		}
+
+		if names[v.Name] {
+			return ConsolidatedPair{}, fmt.Errorf("venue %s is listed twice", v.Name)
+		}
+
+		names[v.Name] = true
+
+		venueBase, venueQuote, ok := splitProductID(v.ProductID)
+		if !ok {
+			return ConsolidatedPair{}, fmt.Errorf("venue %s: product id %q must be base-quote", v.Name, v.ProductID)
+		}
+
+		if i == 0 {
+			base, quote = venueBase, venueQuote
+		} else if venueBase != base || venueQuote != quote {
+			return ConsolidatedPair{}, fmt.Errorf("venue %s: %s does not trade %s-%s like the other venues", v.Name, v.ProductID, base, quote)
+		}
+
+		if v.Weight.Cmp(entity.Decimal{}) < 0 {
+			return ConsolidatedPair{}, fmt.Errorf("venue %s: weight must not be negative", v.Name)
+		}
+
+		pair.Venues = append(pair.Venues, Venue(v))
+	}
+
+	return pair, nil
+}
+
+func (c checkpointFile) parse() (Checkpoint, error) {
+	if len(c.File) == 0 {
+		return Checkpoint{}, fmt.Errorf("file is required")
+	}
+
+	checkpoint := Checkpoint{
+		File:     c.File,
+		Interval: DefaultCheckpointInterval,
+		MaxAge:   DefaultCheckpointMaxAge,
+	}
+
+	if len(c.Interval) > 0 {
+		interval, err := time.ParseDuration(c.Interval)
+		if err != nil || interval < 0 {
+			return Checkpoint{}, fmt.Errorf("invalid interval %q", c.Interval)
+		}
+
+		checkpoint.Interval = interval
+	}
+
+	if len(c.MaxAge) > 0 {
+		maxAge, err := time.ParseDuration(c.MaxAge)
+		if err != nil || maxAge < 0 {
+			return Checkpoint{}, fmt.Errorf("invalid max_age %q", c.MaxAge)
+		}
+
+		checkpoint.MaxAge = maxAge
+	}
+
+	return checkpoint, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nolint: tagliatelle
+type horizonFile struct {
+	Name           string         `json:"name,omitempty"`
+	MaxDataPoints  int64          `json:"max_data_points,omitempty"`
+	WindowDuration string         `json:"window_duration,omitempty"`
+	MaxVolume      entity.Decimal `json:"max_volume,omitempty"`
+	Session        string         `json:"session,omitempty"`
+	HalfLife       string         `json:"half_life,omitempty"`
+	Method         string         `json:"method,omitempty"`
+}
+
+func (h horizonFile) parse() (Horizon, error) {
+	horizon := Horizon{
+		Name:          h.Name,
+		MaxDataPoints: h.MaxDataPoints,
+		Session:       h.Session,
+		Method:        entity.VWAP,
+	}
+
+	switch entity.Method(strings.ToLower(h.Method)) {
+	case "", entity.VWAP:
+	case entity.TWAP:
+		if len(h.WindowDuration) == 0 {
+			return Horizon{}, fmt.Errorf("horizon %q: twap requires window_duration", h.Name)
+		}
+
+		if d, err := time.ParseDuration(h.WindowDuration); err == nil && d <= 0 {
+			return Horizon{}, fmt.Errorf("horizon %q: twap requires a positive window_duration", h.Name)
+		}
+
+		horizon.Method = entity.TWAP
+	default:
+		return Horizon{}, fmt.Errorf("horizon %q: unknown method %q", h.Name, h.Method)
+	}
+
+	if h.MaxDataPoints < 0 {
+		return Horizon{}, fmt.Errorf("horizon %q: max_data_points must be positive", h.Name)
+	}
+
+	windows := 0
+
+	for _, set := range []bool{h.MaxDataPoints > 0, len(h.WindowDuration) > 0, !h.MaxVolume.IsZero(), len(h.Session) > 0, len(h.HalfLife) > 0} {
+		if set {
+			windows++
+		}
+	}
+
+	switch {
+	case windows > 1:
+		return Horizon{}, fmt.Errorf("horizon %q: only one of max_data_points, window_duration, max_volume, session and half_life can be set", h.Name)
+	case len(h.HalfLife) > 0:
+		d, err := time.ParseDuration(h.HalfLife)
+		if err != nil {
+			return Horizon{}, fmt.Errorf("horizon %q: %w", h.Name, err)
+		}
+
+		if d <= 0 {
+			return Horizon{}, fmt.Errorf("horizon %q: half_life must be positive", h.Name)
+		}
+
+		horizon.HalfLife = d
+
+		if len(horizon.Name) == 0 {
+			horizon.Name = h.HalfLife + " half-life"
+		}
+	case !h.MaxVolume.IsZero():
+		if h.MaxVolume.Cmp(entity.Decimal{}) < 0 {
+			return Horizon{}, fmt.Errorf("horizon %q: max_volume must be positive", h.Name)
+		}
+
+		horizon.MaxVolume = h.MaxVolume
+
+		if len(horizon.Name) == 0 {
+			horizon.Name = h.MaxVolume.String() + " volume"
+		}
+	case len(h.Session) > 0:
+		if len(horizon.Name) == 0 {
+			horizon.Name = h.Session
+		}
+	case len(h.WindowDuration) > 0:
+		d, err := time.ParseDuration(h.WindowDuration)
+		if err != nil {
+			return Horizon{}, fmt.Errorf("horizon %q: %w", h.Name, err)
+		}
+
+		// a window without duration would never evict a point
+		if d <= 0 {
+			return Horizon{}, fmt.Errorf("horizon %q: window_duration must be positive", h.Name)
+		}
+
+		horizon.WindowDuration = d
+
+		if len(horizon.Name) == 0 {
+			horizon.Name = h.WindowDuration
+		}
+	case h.MaxDataPoints > 0:
+		if len(horizon.Name) == 0 {
+			horizon.Name = strconv.FormatInt(h.MaxDataPoints, 10)
+		}
+	default:
+		return Horizon{}, fmt.Errorf("horizon %q: one of max_data_points, window_duration, max_volume, session and half_life must be set", h.Name)
+	}
+
+	return horizon, nil
 }
@@ -0,0 +1,145 @@
+package conf
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestParseConfFile(t *testing.T) {
+	conf, err := parseConfFile([]byte(`{
+    "endpoint": "wss://ws-feed.exchange.coinbase.com",
+    "trading_pairs": ["BTC-USD", "ETH-USD", "ETH-BTC"],
+    "max_data_points": 200,
+    "filters": { "min_volume": "0.001" },
+    "warm_up": { "min_points": 20, "min_span": "1m" },
+    "pairs": {
+        "BTC-USD": {
+            "max_data_points": 1000,
+            "filters": { "min_volume": "0.01" },
+            "warm_up": { "min_volume": "1.5" }
+        },
+        "ETH-BTC": {
+            "window_duration": "15m"
+        }
+    }
+}`))
+
+	assert.Nil(t, err)
+
+	setDefaultHorizons(&conf)
+
+	btc := conf.Pairs["BTC-USD"]
+	assert.Equal(t, []Horizon{{Name: "1000", MaxDataPoints: 1000, Method: entity.VWAP}}, btc.Horizons)
+	assert.Equal(t, entity.MustParseDecimal("0.01"), btc.Filters.MinVolume)
+	assert.Equal(t, WarmUp{MinVolume: entity.MustParseDecimal("1.5")}, btc.WarmUp)
+
+	// the pair without section gets the global window and filters
+	eth := conf.Pairs["ETH-USD"]
+	assert.Equal(t, []Horizon{{Name: "200", MaxDataPoints: 200, Method: entity.VWAP}}, eth.Horizons)
+	assert.Equal(t, entity.MustParseDecimal("0.001"), eth.Filters.MinVolume)
+	assert.Equal(t, WarmUp{MinPoints: 20, MinSpan: time.Minute}, eth.WarmUp)
+
+	ethBtc := conf.Pairs["ETH-BTC"]
+	assert.Equal(t, []Horizon{{Name: "15m", WindowDuration: 15 * time.Minute, Method: entity.VWAP}}, ethBtc.Horizons)
+	assert.Equal(t, entity.MustParseDecimal("0.001"), ethBtc.Filters.MinVolume)
+}
+
+func TestParseConfFileCheckpoint(t *testing.T) {
+	conf, err := parseConfFile([]byte(`{"trading_pairs": ["BTC-USD"], "checkpoint": {"file": "/tmp/vwap.json", "max_age": "10m"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, Checkpoint{File: "/tmp/vwap.json", Interval: DefaultCheckpointInterval, MaxAge: 10 * time.Minute}, conf.Checkpoint)
+}
+
+func TestParseConfFileIndicators(t *testing.T) {
+	conf, err := parseConfFile([]byte(`{"trading_pairs": ["BTC-USD"], "pairs": {"BTC-USD": {"indicators": [{"type": "rsi", "params": {"period": 14}}]}}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []Indicator{{Type: "rsi", Params: json.RawMessage(`{"period": 14}`)}}, conf.Pairs["BTC-USD"].Indicators)
+}
+
+func TestParseConfFileReadme(t *testing.T) {
+	readme, err := os.ReadFile("../../README.md")
+	assert.Nil(t, err)
+
+	// the example follows "Configuration file:"
+	const header = "Configuration file:\n```json\n"
+
+	start := strings.Index(string(readme), header)
+	if !assert.True(t, start >= 0, "the readme should have an example") {
+		return
+	}
+
+	example := string(readme)[start+len(header):]
+
+	end := strings.Index(example, "```")
+	if !assert.True(t, end >= 0, "the example should be closed") {
+		return
+	}
+
+	example = example[:end]
+
+	_, err = parseConfFile([]byte(example))
+	assert.Nil(t, err, "the example of the readme should be valid")
+}
+
+func TestParseConfFileConsolidatedPairs(t *testing.T) {
+	conf, err := parseConfFile([]byte(`{"trading_pairs": ["BTC-USD"], "consolidated_pairs": [{"symbol": "BTC", "window": {"name": "500", "max_volume": "500"},
+		"venues": [{"name": "a", "endpoint": "ws://a", "product_id": "BTC-USD"}, {"name": "b", "endpoint": "ws://b", "product_id": "BTC-USD", "weight": "0.5", "excluded": true}]}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []ConsolidatedPair{{
+		Symbol: "BTC",
+		Window: Horizon{Name: "500", MaxVolume: entity.DecimalFromInt(500), Method: entity.VWAP},
+		Venues: []Venue{
+			{Name: "a", Endpoint: "ws://a", ProductID: "BTC-USD"},
+			{Name: "b", Endpoint: "ws://b", ProductID: "BTC-USD", Weight: entity.MustParseDecimal("0.5"), Excluded: true},
+		},
+	}}, conf.ConsolidatedPairs)
+}
+
+func TestParseConfFileSyntheticPairs(t *testing.T) {
+	conf, err := parseConfFile([]byte(`{"trading_pairs": ["ETH-BTC", "BTC-USD"], "max_data_points": 100,
+		"pairs": {"BTC-USD": {"horizons": [{"max_data_points": 100}, {"window_duration": "5m"}]}},
+		"synthetic_pairs": [{"product_id": "ETH-USD", "legs": ["ETH-BTC", "BTC-USD"], "horizon": "100"}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []SyntheticPair{{ProductID: "ETH-USD", Legs: []string{"ETH-BTC", "BTC-USD"}, Horizon: "100"}}, conf.SyntheticPairs)
+}
+
+func TestParseConfFileErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":           `{"trading_pairs": ["BTC-USD"], "max_data_point": 10}`,
+		"unknown pair key":      `{"trading_pairs": ["BTC-USD"], "pairs": {"BTC-USD": {"window": "5m"}}}`,
+		"unknown horizon key":   `{"trading_pairs": ["BTC-USD"], "pairs": {"BTC-USD": {"horizons": [{"size": 5}]}}}`,
+		"unknown pair":          `{"trading_pairs": ["BTC-USD"], "pairs": {"ETH-USD": {"max_data_points": 5}}}`,
+		"two windows":           `{"trading_pairs": ["BTC-USD"], "pairs": {"BTC-USD": {"horizons": [{"max_data_points": 5, "window_duration": "5m"}]}}}`,
+		"invalid duration":      `{"trading_pairs": ["BTC-USD"], "pairs": {"BTC-USD": {"window_duration": "5 minutes"}}}`,
+		"indicator no type":     `{"trading_pairs": ["BTC-USD"], "pairs": {"BTC-USD": {"indicators": [{"name": "rsi"}]}}}`,
+		"consolidated session":  `{"trading_pairs": ["A"], "consolidated_pairs": [{"symbol": "X", "window": {"session": "@daily"}, "venues": [{"name": "a", "endpoint": "ws://a", "product_id": "X-USD"}, {"name": "b", "endpoint": "ws://b", "product_id": "X-USD"}]}]}`,
+		"consolidated quote":    `{"trading_pairs": ["BTC-USD"], "consolidated_pairs": [{"symbol": "BTC", "window": {"max_data_points": 5}, "venues": [{"name": "a", "endpoint": "ws://a", "product_id": "BTC-USD"}, {"name": "b", "endpoint": "ws://b", "product_id": "BTC-USDT"}]}]}`,
+		"consolidated duration": `{"trading_pairs": ["BTC-USD"], "consolidated_pairs": [{"symbol": "BTC", "window": {"window_duration": "5m"}, "venues": [{"name": "a", "endpoint": "ws://a", "product_id": "BTC-USD"}, {"name": "b", "endpoint": "ws://b", "product_id": "BTC-USD"}]}]}`,
+		"consolidated endpoint": `{"trading_pairs": ["BTC-USD"], "consolidated_pairs": [{"symbol": "BTC", "window": {"max_data_points": 5}, "venues": [{"name": "a", "endpoint": "ws://a", "product_id": "BTC-USD"}, {"name": "b", "product_id": "BTC-USD"}]}]}`,
+		"consolidated venue":    `{"trading_pairs": ["BTC-USD"], "consolidated_pairs": [{"symbol": "BTC", "window": {"max_data_points": 5}, "venues": [{"name": "a", "endpoint": "ws://a", "product_id": "BTC-USD"}, {"name": "a", "endpoint": "ws://a", "product_id": "BTC-USD"}]}]}`,
+		"venue endpoints":       `{"trading_pairs": ["BTC-USD"], "consolidated_pairs": [{"symbol": "BTC", "window": {"max_data_points": 5}, "venues": [{"name": "a", "endpoint": "ws://a", "product_id": "BTC-USD"}, {"name": "b", "endpoint": "ws://b", "product_id": "BTC-USD"}]}, {"symbol": "ETH", "window": {"max_data_points": 5}, "venues": [{"name": "a", "endpoint": "ws://c", "product_id": "ETH-USD"}, {"name": "b", "endpoint": "ws://b", "product_id": "ETH-USD"}]}]}`,
+		"negative max points":   `{"trading_pairs": ["BTC-USD"], "max_data_points": -5}`,
+		"negative horizon size": `{"trading_pairs": ["BTC-USD"], "pairs": {"BTC-USD": {"horizons": [{"max_data_points": -5, "window_duration": "5m"}]}}}`,
+		"zero duration":         `{"trading_pairs": ["BTC-USD"], "pairs": {"BTC-USD": {"horizons": [{"window_duration": "0s"}]}}}`,
+		"negative duration":     `{"trading_pairs": ["BTC-USD"], "pairs": {"BTC-USD": {"window_duration": "-5m"}}}`,
+		"duplicated horizon":    `{"trading_pairs": ["BTC-USD"], "pairs": {"BTC-USD": {"horizons": [{"window_duration": "5m"}, {"name": "5m", "max_data_points": 10}]}}}`,
+		"zero twap duration":    `{"trading_pairs": ["BTC-USD"], "pairs": {"BTC-USD": {"horizons": [{"method": "twap", "window_duration": "0s"}]}}}`,
+		"checkpoint no file":    `{"trading_pairs": ["BTC-USD"], "checkpoint": {"interval": "1m"}}`,
+		"synthetic broken legs": `{"trading_pairs": ["ETH-BTC", "USD-BTC"], "synthetic_pairs": [{"product_id": "ETH-USD", "legs": ["ETH-BTC", "USD-BTC"], "horizon": "200"}]}`,
+		"synthetic wrong quote": `{"trading_pairs": ["ETH-BTC", "BTC-EUR"], "synthetic_pairs": [{"product_id": "ETH-USD", "legs": ["ETH-BTC", "BTC-EUR"], "horizon": "200"}]}`,
+		"synthetic horizon":     `{"trading_pairs": ["ETH-BTC", "BTC-USD"], "synthetic_pairs": [{"product_id": "ETH-USD", "legs": ["ETH-BTC", "BTC-USD"], "horizon": "5m"}]}`,
+		"synthetic twap":        `{"trading_pairs": ["ETH-BTC", "BTC-USD"], "pairs": {"ETH-BTC": {"window_duration": "5m"}, "BTC-USD": {"horizons": [{"name": "5m", "method": "twap", "window_duration": "5m"}]}}, "synthetic_pairs": [{"product_id": "ETH-USD", "legs": ["ETH-BTC", "BTC-USD"], "horizon": "5m"}]}`,
+	}
+
+	for name, content := range tests {
+		_, err := parseConfFile([]byte(content))
+		assert.NotNil(t, err, name)
+	}
+}
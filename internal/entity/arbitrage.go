@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+// ArbitrageAlert is a cycle of trades through several currencies (e.g. USD→BTC→ETH→USD) which ends with more than it started with.
+type ArbitrageAlert struct {
+	// Cycle -- the currencies of the cycle, the first one being the start and the end of the cycle
+	Cycle []string
+	// Horizon -- name of the window of the vwaps used as prices
+	Horizon string
+	// Timestamp -- timestamp of the detection
+	Timestamp time.Time
+	// Legs -- the trades of the cycle in order
+	Legs []ArbitrageLeg
+	// GrossEdgeBps -- profit of the cycle before fees in basis points
+	GrossEdgeBps float64
+	// EdgeBps -- profit of the cycle after fees in basis points
+	EdgeBps float64
+	// Skew -- time between the oldest and the newest vwap of the legs
+	Skew time.Duration
+}
+
+// ArbitrageLeg is a trade of an arbitrage cycle at the vwap of its pair.
+type ArbitrageLeg struct {
+	ProductID string
+	// Side -- buy if the base currency of the pair is bought with the quote currency, sell otherwise
+	Side  Side
+	Price Decimal
+	// Timestamp -- timestamp of the ticker which produced the vwap
+	Timestamp time.Time
+}
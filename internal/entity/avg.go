@@ -2,13 +2,93 @@ package entity
 
 import "time"
 
+// Method is the kind of average.
+type Method string
+
+const (
+	// VWAP -- volume weighted average price.
+	VWAP Method = "vwap"
+	// TWAP -- time weighted average price.
+	TWAP Method = "twap"
+)
+
 type AverageResult struct {
 	// ProductID -- id of the product
 	ProductID string
+	// Method -- kind of average
+	Method Method
+	// Horizon -- name of the window used in calculation
+	Horizon string
 	// Timestamp -- timestamp of the calculation
 	Timestamp time.Time
-	// Average -- actual value of the average
-	Average float64
+	// Average -- actual value of the average rounded to the quote increment of the product
+	Average Decimal
+	// ExactAverage -- the average before rounding. The cross rates and the arbitrage edges are computed from it.
+	ExactAverage Decimal
+	// StdDev -- volume weighted standard deviation of the points used in calculation
+	StdDev Decimal
+	// Bands -- bands of a number of standard deviations around the average
+	Bands []Band
+	// OrderFlow -- averages and volumes of the buy and sell trades used in calculation
+	OrderFlow OrderFlow
+	// Median -- volume weighted median of the prices. Zero if not computed.
+	Median Decimal
+	// Percentiles -- volume weighted percentiles of the prices
+	Percentiles []Percentile
 	// TotalPoints -- number of points used in calculation
 	TotalPoints int
+	// Window -- number of points, volume and time span of the window. Zero for the result of a closed session.
+	Window WindowStats
+	// SessionStart -- for session horizons, the anchor of the session. Zero otherwise.
+	SessionStart time.Time
+	// SessionClose -- true if the result is the final average of a session which just closed
+	SessionClose bool
+	// WarmingUp -- true while the window does not meet the warm-up thresholds of the pair. The average is not a reliable benchmark yet.
+	WarmingUp bool
+	// Degraded -- true if messages of the product were lost and the window still holds points received before the gap
+	Degraded bool
+}
+
+// WindowStats describes the points used in calculation.
+type WindowStats struct {
+	// TotalPoints -- number of points
+	TotalPoints int
+	// Volume -- total volume of the points
+	Volume Decimal
+	// Start -- timestamp of the oldest point
+	Start time.Time
+	// End -- timestamp of the newest point
+	End time.Time
+}
+
+// Span returns the time between the oldest and the newest point.
+func (s WindowStats) Span() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Band is a band of Multiplier standard deviations around the average.
+type Band struct {
+	Multiplier float64
+	// Upper -- average + Multiplier * StdDev
+	Upper Decimal
+	// Lower -- average - Multiplier * StdDev
+	Lower Decimal
+}
+
+// Percentile is the lowest price such that the trades at or below it hold at least Percentile % of the volume.
+type Percentile struct {
+	Percentile float64
+	Value      Decimal
+}
+
+// OrderFlow splits the trades by the side of the taker.
+type OrderFlow struct {
+	// BuyAverage -- volume weighted average of the buy trades
+	BuyAverage Decimal
+	// SellAverage -- volume weighted average of the sell trades
+	SellAverage Decimal
+	BuyVolume   Decimal
+	SellVolume  Decimal
+	// Imbalance -- (BuyVolume - SellVolume) / (BuyVolume + SellVolume). It goes from -1 (only sells) to 1 (only buys).
+	Imbalance float64
 }
@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// Candle is an OHLCV bar of the trades of a product over an interval.
+type Candle struct {
+	// ProductID -- id of the product
+	ProductID string
+	// Interval -- duration of the bar
+	Interval time.Duration
+	// Start -- start of the bar. The bar covers [Start, Start + Interval).
+	Start time.Time
+	Open  Decimal
+	High  Decimal
+	Low   Decimal
+	Close Decimal
+	// Volume -- total volume traded during the bar
+	Volume Decimal
+	// VWAP -- volume weighted average price of the bar
+	VWAP Decimal
+	// TradeCount -- number of trades of the bar
+	TradeCount int
+}
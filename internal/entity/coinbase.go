@@ -2,14 +2,27 @@ package entity
 
 import "time"
 
+// Side is the side of the taker of a trade.
+type Side string
+
+const (
+	Buy  Side = "buy"
+	Sell Side = "sell"
+)
+
 // Ticker represent the json ticker message from coinbase.
+// Price and volume are sent as strings and decoded into decimals to keep their exact value.
 // nolint: tagliatelle
 type Ticker struct {
 	Sequence  int64     `json:"sequence"`
+	TradeID   int64     `json:"trade_id"`
 	ProductID string    `json:"product_id"`
-	Price     float64   `json:"price,string"`
-	Volume    float64   `json:"last_size,string"`
+	Price     Decimal   `json:"price"`
+	Volume    Decimal   `json:"last_size"`
+	Side      Side      `json:"side"`
 	Timestamp time.Time `json:"time"`
+	// Venue -- name of the venue whose feed sent the ticker. Empty for the tickers of the trading pairs.
+	Venue string `json:"-"`
 }
 
 // Heartbeat message.
@@ -0,0 +1,32 @@
+package entity
+
+import "time"
+
+// ConsolidatedResult is the vwap of the trades of an asset merged from several venues.
+type ConsolidatedResult struct {
+	// Symbol -- canonical symbol of the asset
+	Symbol string
+	// Horizon -- name of the window used in calculation
+	Horizon string
+	// Timestamp -- timestamp of the calculation
+	Timestamp time.Time
+	// Average -- consolidated vwap. The volume of each trade is multiplied by the weight of its venue.
+	Average Decimal
+	// StdDev -- volume weighted standard deviation of the consolidated window
+	StdDev Decimal
+	// TotalPoints -- number of points used in calculation
+	TotalPoints int
+	// Venues -- contribution of each venue to the window, sorted by venue
+	Venues []VenueShare
+}
+
+// VenueShare is the contribution of a venue to a consolidated window.
+type VenueShare struct {
+	Venue string
+	// Average -- vwap of the trades of the venue in the window
+	Average Decimal
+	// Volume -- weighted volume of the trades of the venue in the window
+	Volume Decimal
+	// Share -- Volume / total weighted volume of the window. It goes from 0 to 1.
+	Share float64
+}
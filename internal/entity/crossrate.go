@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// CrossRate compares the vwap of a pair implied by the product of the vwaps of its legs with the vwap of the pair when it is traded.
+// E.g. ETH-USD is implied by ETH-BTC * BTC-USD.
+type CrossRate struct {
+	// ProductID -- id of the synthetic pair
+	ProductID string
+	// Horizon -- name of the window of the vwaps
+	Horizon string
+	// Timestamp -- timestamp of the calculation
+	Timestamp time.Time
+	// Legs -- vwaps of the component pairs
+	Legs []CrossRateLeg
+	// Implied -- product of the vwaps of the legs
+	Implied Decimal
+	// Direct -- vwap of the pair itself. Zero if the pair is not traded or has no vwap yet.
+	Direct Decimal
+	// Basis -- Implied - Direct. Zero if there is no direct vwap.
+	Basis Decimal
+	// BasisBps -- Basis relative to Direct in basis points
+	BasisBps float64
+	// WarmingUp -- true if the vwap of a leg or the direct vwap is still warming up. The cross rate is not reliable yet.
+	WarmingUp bool
+	// Degraded -- true if the vwap of a leg or the direct vwap is degraded by lost trades
+	Degraded bool
+}
+
+// CrossRateLeg is the vwap of a component pair of a cross rate.
+type CrossRateLeg struct {
+	ProductID string
+	// Average -- vwap of the pair before rounding to its quote increment
+	Average Decimal
+	// Timestamp -- timestamp of the ticker which produced the vwap
+	Timestamp time.Time
+	WarmingUp bool
+	Degraded  bool
+}
@@ -0,0 +1,245 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// DecimalPlaces is the number of digits after the decimal point kept by Decimal.
+// 8 places are enough to represent exactly the prices and sizes sent by coinbase (1 satoshi = 0.00000001).
+const DecimalPlaces = 8
+
+// DecimalScale is the number of units in 1.
+const DecimalScale = 100_000_000
+
+// ErrDecimalSyntax means that the string is not a decimal number.
+var ErrDecimalSyntax = errors.New("invalid decimal syntax")
+
+// ErrDecimalPrecision means that the number has more than DecimalPlaces significant digits after the decimal point.
+var ErrDecimalPrecision = errors.New("decimal precision exceeded")
+
+// ErrDecimalRange means that the number does not fit in a Decimal.
+var ErrDecimalRange = errors.New("decimal out of range")
+
+// Decimal is a fixed-point decimal number with DecimalPlaces digits after the decimal point.
+// It is stored as an integer number of units of 10^-DecimalPlaces so the exchange values are kept exactly.
+// The zero value is 0.
+type Decimal struct {
+	units int64
+}
+
+// NewDecimal returns the decimal units * 10^-DecimalPlaces.
+func NewDecimal(units int64) Decimal {
+	return Decimal{units}
+}
+
+// DecimalFromInt returns the decimal equal to i.
+func DecimalFromInt(i int64) Decimal {
+	return Decimal{i * DecimalScale}
+}
+
+// DecimalFromFloat returns the decimal nearest to f.
+func DecimalFromFloat(f float64) Decimal {
+	return Decimal{int64(math.Round(f * DecimalScale))}
+}
+
+// ParseDecimal parses a decimal number like "-123.456".
+func ParseDecimal(s string) (Decimal, error) {
+	str := s
+
+	negative := false
+	if strings.HasPrefix(str, "-") {
+		negative = true
+		str = str[1:]
+	}
+
+	intPart, fracPart := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		intPart, fracPart = str[:i], str[i+1:]
+	}
+
+	if len(intPart) == 0 && len(fracPart) == 0 {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrDecimalSyntax, s)
+	}
+
+	// drop the trailing zeros which do not change the value
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > DecimalPlaces {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrDecimalPrecision, s)
+	}
+
+	fracPart += strings.Repeat("0", DecimalPlaces-len(fracPart))
+
+	var units uint64
+
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return Decimal{}, fmt.Errorf("%w: %q", ErrDecimalSyntax, s)
+		}
+
+		if units > (math.MaxInt64-uint64(r-'0'))/10 {
+			return Decimal{}, fmt.Errorf("%w: %q", ErrDecimalRange, s)
+		}
+
+		units = units*10 + uint64(r-'0')
+	}
+
+	if negative {
+		return Decimal{-int64(units)}, nil
+	}
+
+	return Decimal{int64(units)}, nil
+}
+
+// MustParseDecimal is like ParseDecimal but panics if the string cannot be parsed.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+// Units returns the number of 10^-DecimalPlaces units.
+func (d Decimal) Units() int64 {
+	return d.units
+}
+
+func (d Decimal) Add(o Decimal) Decimal {
+	return Decimal{d.units + o.units}
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	return Decimal{d.units - o.units}
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{-d.units}
+}
+
+// Mul returns d * o rounded to DecimalPlaces. Halves are rounded away from zero.
+// The product is computed in 128 bits. It returns ErrDecimalRange if the result does not fit in a Decimal.
+func (d Decimal) Mul(o Decimal) (Decimal, error) {
+	hi, lo := bits.Mul64(d.abs(), o.abs())
+
+	// the quotient would not fit in 64 bits
+	if hi >= DecimalScale {
+		return Decimal{}, fmt.Errorf("%w: %s * %s", ErrDecimalRange, d, o)
+	}
+
+	q, r := bits.Div64(hi, lo, DecimalScale)
+	if 2*r >= DecimalScale {
+		q++
+	}
+
+	if q > math.MaxInt64 {
+		return Decimal{}, fmt.Errorf("%w: %s * %s", ErrDecimalRange, d, o)
+	}
+
+	if (d.units < 0) != (o.units < 0) {
+		return Decimal{-int64(q)}, nil
+	}
+
+	return Decimal{int64(q)}, nil
+}
+
+// Cmp returns -1 if d < o, 0 if d == o and 1 if d > o.
+func (d Decimal) Cmp(o Decimal) int {
+	switch {
+	case d.units < o.units:
+		return -1
+	case d.units > o.units:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+// Float64 returns the nearest float64 of d.
+func (d Decimal) Float64() float64 {
+	return float64(d.units) / DecimalScale
+}
+
+// Round rounds d to the nearest multiple of increment. Halves are rounded away from zero.
+// If increment is not positive, d is returned unchanged.
+func (d Decimal) Round(increment Decimal) Decimal {
+	if increment.units <= 0 {
+		return d
+	}
+
+	q, r := d.units/increment.units, d.units%increment.units
+
+	switch {
+	case r > 0 && 2*r >= increment.units:
+		q++
+	case r < 0 && -2*r >= increment.units:
+		q--
+	}
+
+	return Decimal{q * increment.units}
+}
+
+// abs returns the absolute value of the units. It does not overflow for math.MinInt64.
+func (d Decimal) abs() uint64 {
+	if d.units < 0 {
+		return uint64(-d.units)
+	}
+
+	return uint64(d.units)
+}
+
+// String returns the decimal without the trailing zeros after the decimal point.
+func (d Decimal) String() string {
+	u := d.units
+
+	sign := ""
+	if u < 0 {
+		sign = "-"
+	}
+
+	abs := d.abs()
+
+	intPart := strconv.FormatUint(abs/DecimalScale, 10)
+
+	fracPart := strings.TrimRight(fmt.Sprintf("%0*d", DecimalPlaces, abs%DecimalScale), "0")
+	if len(fracPart) == 0 {
+		return sign + intPart
+	}
+
+	return sign + intPart + "." + fracPart
+}
+
+// MarshalJSON encodes the decimal as a json string to keep its exact value.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON decodes a decimal from either a json string (as sent by coinbase) or a json number.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	v, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+
+	*d = v
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package entity_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		err      error
+	}{
+		{input: "1", expected: 100_000_000},
+		{input: "0.00000001", expected: 1},
+		{input: "65432.12000000000", expected: 6_543_212_000_000},
+		{input: "-1.5", expected: -150_000_000},
+		{input: ".5", expected: 50_000_000},
+		{input: "0.000000001", err: entity.ErrDecimalPrecision},
+		{input: "1e5", err: entity.ErrDecimalSyntax},
+		{input: "", err: entity.ErrDecimalSyntax},
+		{input: "-", err: entity.ErrDecimalSyntax},
+		{input: "100000000000", err: entity.ErrDecimalRange},
+	}
+
+	for _, test := range tests {
+		d, err := entity.ParseDecimal(test.input)
+		if test.err != nil {
+			assert.ErrorIs(t, err, test.err, "input: %s", test.input)
+
+			continue
+		}
+
+		assert.Nil(t, err, "input: %s", test.input)
+		assert.Equal(t, test.expected, d.Units(), "input: %s", test.input)
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	assert.Equal(t, "0", entity.Decimal{}.String())
+	assert.Equal(t, "0.00000001", entity.NewDecimal(1).String())
+	assert.Equal(t, "-1.5", entity.MustParseDecimal("-1.50").String())
+	assert.Equal(t, "65432", entity.DecimalFromInt(65432).String())
+}
+
+func TestDecimalRound(t *testing.T) {
+	increment := entity.MustParseDecimal("0.01")
+
+	assert.Equal(t, "1.23", entity.MustParseDecimal("1.234").Round(increment).String())
+	assert.Equal(t, "1.24", entity.MustParseDecimal("1.235").Round(increment).String())
+	assert.Equal(t, "-1.24", entity.MustParseDecimal("-1.235").Round(increment).String())
+	assert.Equal(t, "1.25", entity.MustParseDecimal("1.26").Round(entity.MustParseDecimal("0.05")).String())
+
+	// no increment
+	assert.Equal(t, "1.234", entity.MustParseDecimal("1.234").Round(entity.Decimal{}).String())
+}
+
+func TestDecimalMul(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{a: "0.07512", b: "65432.12", expected: "4915.2608544"},
+		{a: "-1.5", b: "2", expected: "-3"},
+		{a: "-1.5", b: "-2", expected: "3"},
+		// 0.000000005 is rounded away from zero
+		{a: "0.00000001", b: "0.5", expected: "0.00000001"},
+		{a: "-0.00000001", b: "0.5", expected: "-0.00000001"},
+		{a: "0.00000001", b: "0.49999999", expected: "0"},
+	}
+
+	for _, test := range tests {
+		product, err := entity.MustParseDecimal(test.a).Mul(entity.MustParseDecimal(test.b))
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, product.String(), "%s * %s", test.a, test.b)
+	}
+
+	_, err := entity.DecimalFromInt(10_000_000_000).Mul(entity.DecimalFromInt(10_000_000_000))
+	assert.ErrorIs(t, err, entity.ErrDecimalRange)
+}
+
+func TestDecimalJSON(t *testing.T) {
+	var ticker entity.Ticker
+
+	err := json.Unmarshal([]byte(`{"price": "65432.12", "last_size": 0.00000001}`), &ticker)
+	assert.Nil(t, err)
+	assert.Equal(t, entity.MustParseDecimal("65432.12"), ticker.Price)
+	assert.Equal(t, entity.NewDecimal(1), ticker.Volume)
+
+	err = json.Unmarshal([]byte(`{"price": "abc"}`), &ticker)
+	assert.ErrorIs(t, err, entity.ErrDecimalSyntax)
+
+	b, err := json.Marshal(entity.MustParseDecimal("0.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, `"0.1"`, string(b))
+}
@@ -0,0 +1,24 @@
+package entity
+
+// TradeGap is a range of trade ids which were never received.
+type TradeGap struct {
+	// From -- first missing trade id
+	From int64
+	// To -- last missing trade id
+	To int64
+}
+
+// Missing returns the number of trades of the gap.
+func (g TradeGap) Missing() int64 {
+	return g.To - g.From + 1
+}
+
+// TradeGaps holds the gaps detected in the trade ids of the tickers of a product.
+type TradeGaps struct {
+	// Count -- number of gaps still open. A gap is closed once all its trades arrived late.
+	Count int
+	// Missing -- total number of trades still missing
+	Missing int64
+	// Ranges -- the last detected gaps, oldest first
+	Ranges []TradeGap
+}
@@ -0,0 +1,69 @@
+package entity
+
+import (
+	"strconv"
+	"time"
+)
+
+// ValueKind is the type of an indicator value.
+type ValueKind int
+
+const (
+	DecimalValue ValueKind = iota
+	FloatValue
+	IntValue
+	BoolValue
+)
+
+// IndicatorValue is a named value computed by an indicator. Only the field of its kind is set.
+type IndicatorValue struct {
+	// Name -- name of the value (e.g. "rsi")
+	Name string
+	Kind ValueKind
+
+	Decimal Decimal
+	Float   float64
+	Int     int64
+	Bool    bool
+}
+
+func NewDecimalValue(name string, v Decimal) IndicatorValue {
+	return IndicatorValue{Name: name, Kind: DecimalValue, Decimal: v}
+}
+
+func NewFloatValue(name string, v float64) IndicatorValue {
+	return IndicatorValue{Name: name, Kind: FloatValue, Float: v}
+}
+
+func NewIntValue(name string, v int64) IndicatorValue {
+	return IndicatorValue{Name: name, Kind: IntValue, Int: v}
+}
+
+func NewBoolValue(name string, v bool) IndicatorValue {
+	return IndicatorValue{Name: name, Kind: BoolValue, Bool: v}
+}
+
+// String returns the value formatted according to its kind.
+func (v IndicatorValue) String() string {
+	switch v.Kind {
+	case FloatValue:
+		return strconv.FormatFloat(v.Float, 'f', 4, 64)
+	case IntValue:
+		return strconv.FormatInt(v.Int, 10)
+	case BoolValue:
+		return strconv.FormatBool(v.Bool)
+	default:
+		return v.Decimal.String()
+	}
+}
+
+// IndicatorResult holds the values computed by an indicator for a ticker.
+type IndicatorResult struct {
+	// ProductID -- id of the product
+	ProductID string
+	// Indicator -- name of the indicator
+	Indicator string
+	// Timestamp -- timestamp of the calculation
+	Timestamp time.Time
+	Values    []IndicatorValue
+}
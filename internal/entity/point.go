@@ -1,6 +1,12 @@
 package entity
 
+import "time"
+
 type DataPoint struct {
-	Value  float64
-	Volume float64
+	Value     Decimal
+	Volume    Decimal
+	Side      Side
+	Timestamp time.Time
+	// Venue -- venue of the trade in a consolidated window. Empty otherwise.
+	Venue string `json:",omitempty"`
 }
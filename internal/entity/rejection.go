@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// Rejection is a ticker dropped by a filter before being processed.
+type Rejection struct {
+	// Ticker -- the dropped ticker
+	Ticker Ticker
+	// Reason -- why the ticker was dropped
+	Reason string
+	// Timestamp -- time of the rejection
+	Timestamp time.Time
+}
@@ -0,0 +1,246 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tupyy/vwap/internal/entity"
+	"github.com/tupyy/vwap/internal/log"
+)
+
+// ErrUnknownPair means that no subscribed pair trades two consecutive currencies of an arbitrage cycle.
+var ErrUnknownPair = errors.New("no pair between currencies")
+
+// ErrUnknownHorizon means that a pair has no vwap horizon with the name used by an arbitrage cycle.
+var ErrUnknownHorizon = errors.New("unknown vwap horizon")
+
+// HorizonLister is implemented by the calculators which report the names of their vwap horizons.
+type HorizonLister interface {
+	Horizons() []string
+}
+
+// ArbitrageCycle defines a cycle of currencies monitored for triangular arbitrage (e.g. USD, BTC and ETH).
+// Both directions of the cycle are monitored.
+type ArbitrageCycle struct {
+	// Currencies -- the currencies of the cycle. Each pair of consecutive currencies, and the last and the first one, must be traded by a subscribed pair.
+	Currencies []string
+	// Horizon -- name of the vwap horizon of the pairs used as prices
+	Horizon string
+	// FeeBps -- fee paid on each trade in basis points
+	FeeBps float64
+	// MinEdgeBps -- minimum profit of the cycle after fees in basis points to raise an alert
+	MinEdgeBps float64
+}
+
+// arbitrageMonitor watches one direction of an arbitrage cycle.
+type arbitrageMonitor struct {
+	cycle ArbitrageCycle
+	// currencies -- the currencies in the order of the trades, the first one being repeated at the end
+	currencies []string
+	legs       []arbitrageLeg
+	// open -- true while the edge is above the threshold. An alert is raised only when the edge crosses the threshold.
+	open bool
+}
+
+type arbitrageLeg struct {
+	productID string
+	// side -- buy if the base currency of the pair is bought with the quote currency
+	side entity.Side
+}
+
+// AddArbitrageCycle monitors both directions of the cycle. The pairs of the cycle must have been added with AddAvgCalculator.
+// It returns ErrUnknownPair if two consecutive currencies are not traded by a pair and ErrUnknownHorizon
+// if a pair of the cycle has no calculator listing the vwap horizon of the cycle (see HorizonLister).
+func (a *AvgManager) AddArbitrageCycle(cycle ArbitrageCycle) error {
+	n := len(cycle.Currencies)
+	if n < 3 {
+		return fmt.Errorf("arbitrage cycle %v: at least three currencies are required", cycle.Currencies)
+	}
+
+	seen := make(map[string]bool, n)
+
+	for _, c := range cycle.Currencies {
+		if seen[c] {
+			return fmt.Errorf("arbitrage cycle %v: currency %s is repeated", cycle.Currencies, c)
+		}
+
+		seen[c] = true
+	}
+
+	reversed := make([]string, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		reversed = append(reversed, cycle.Currencies[i])
+	}
+
+	// both directions start from the first currency
+	reversed = append(reversed[n-1:], reversed[:n-1]...)
+
+	for _, currencies := range [][]string{cycle.Currencies, reversed} {
+		m, err := a.newArbitrageMonitor(cycle, currencies)
+		if err != nil {
+			return err
+		}
+
+		a.arbitrageMonitors = append(a.arbitrageMonitors, m)
+	}
+
+	return nil
+}
+
+func (a *AvgManager) newArbitrageMonitor(cycle ArbitrageCycle, currencies []string) (*arbitrageMonitor, error) {
+	m := &arbitrageMonitor{
+		cycle:      cycle,
+		currencies: append(append([]string{}, currencies...), currencies[0]),
+		legs:       make([]arbitrageLeg, 0, len(currencies)),
+	}
+
+	for i := 0; i < len(currencies); i++ {
+		from, to := m.currencies[i], m.currencies[i+1]
+
+		// buy "to" with "from" on the pair TO-FROM or sell "from" for "to" on the pair FROM-TO
+		if productID := to + "-" + from; a.hasPair(productID) {
+			m.legs = append(m.legs, arbitrageLeg{productID: productID, side: entity.Buy})
+
+			continue
+		}
+
+		if productID := from + "-" + to; a.hasPair(productID) {
+			m.legs = append(m.legs, arbitrageLeg{productID: productID, side: entity.Sell})
+
+			continue
+		}
+
+		return nil, fmt.Errorf("%w %s and %s", ErrUnknownPair, from, to)
+	}
+
+	for _, leg := range m.legs {
+		if !a.hasHorizon(leg.productID, cycle.Horizon) {
+			return nil, fmt.Errorf("%w: %s has no horizon %q", ErrUnknownHorizon, leg.productID, cycle.Horizon)
+		}
+	}
+
+	return m, nil
+}
+
+func (a *AvgManager) hasPair(productID string) bool {
+	_, found := a.avgCurrencyCalculators[productID]
+
+	return found
+}
+
+// hasHorizon returns true if a calculator of the product lists the vwap horizon.
+func (a *AvgManager) hasHorizon(productID, horizon string) bool {
+	for _, c := range a.avgCurrencyCalculators[productID] {
+		l, ok := c.(HorizonLister)
+		if !ok {
+			continue
+		}
+
+		for _, h := range l.Horizons() {
+			if h == horizon {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkArbitrage checks the cycles which trade the product and writes an alert for each cycle whose edge crossed its threshold.
+func (a *AvgManager) checkArbitrage(productID string) {
+	for _, m := range a.arbitrageMonitors {
+		if !m.tradesOn(productID) {
+			continue
+		}
+
+		alert, ok := m.check(a.latestAverages)
+		if !ok {
+			continue
+		}
+
+		if err := a.outWriter.WriteArbitrage(alert); err != nil {
+			log.GetLogger().Warningf("cannot write arbitrage alert to output: %+v", err)
+		}
+	}
+}
+
+// check returns an alert if the edge of the cycle has just crossed the threshold.
+// It returns false until all the pairs of the cycle have a vwap and while the vwap of a pair is warming up or degraded:
+// the cycle is skipped without changing its state.
+func (m *arbitrageMonitor) check(latestAverages map[averageKey]latestAverage) (entity.ArbitrageAlert, bool) {
+	alert := entity.ArbitrageAlert{
+		Cycle:   m.currencies,
+		Horizon: m.cycle.Horizon,
+		Legs:    make([]entity.ArbitrageLeg, 0, len(m.legs)),
+	}
+
+	gross, net := 1.0, 1.0
+	fee := 1 - m.cycle.FeeBps/10_000
+
+	var oldest, newest time.Time
+
+	for _, leg := range m.legs {
+		latest, found := latestAverages[averageKey{leg.productID, m.cycle.Horizon}]
+		if !found || latest.average.IsZero() || latest.warmingUp || latest.degraded {
+			return entity.ArbitrageAlert{}, false
+		}
+
+		rate := latest.average.Float64()
+		if leg.side == entity.Buy {
+			rate = 1 / rate
+		}
+
+		gross *= rate
+		net *= rate * fee
+
+		if oldest.IsZero() || latest.timestamp.Before(oldest) {
+			oldest = latest.timestamp
+		}
+
+		if latest.timestamp.After(newest) {
+			newest = latest.timestamp
+		}
+
+		alert.Legs = append(alert.Legs, entity.ArbitrageLeg{
+			ProductID: leg.productID,
+			Side:      leg.side,
+			Price:     latest.average,
+			Timestamp: latest.timestamp,
+		})
+	}
+
+	alert.GrossEdgeBps = (gross - 1) * 10_000
+	alert.EdgeBps = (net - 1) * 10_000
+	alert.Skew = newest.Sub(oldest)
+
+	if alert.EdgeBps < m.cycle.MinEdgeBps {
+		if m.open {
+			log.GetLogger().Infof("arbitrage %s closed. edge: %.2f bps", strings.Join(m.currencies, "→"), alert.EdgeBps)
+		}
+
+		m.open = false
+
+		return entity.ArbitrageAlert{}, false
+	}
+
+	if m.open {
+		return entity.ArbitrageAlert{}, false
+	}
+
+	m.open = true
+	alert.Timestamp = time.Now()
+
+	return alert, true
+}
+
+func (m *arbitrageMonitor) tradesOn(productID string) bool {
+	for _, leg := range m.legs {
+		if leg.productID == productID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,42 +2,171 @@ package manager
 
 import (
 	"context"
+	"sync"
 	"time"
 
+	"github.com/tupyy/vwap/internal/compute"
 	"github.com/tupyy/vwap/internal/entity"
 	"github.com/tupyy/vwap/internal/log"
 )
 
 type PairAvgCalculator interface {
 	ProcessHeartBeat(h entity.HeartBeat)
-	ProcessTicker(t entity.Ticker) (avg float64, totalPoints int, err error)
+	// ProcessTicker returns one result for each horizon of the pair.
+	ProcessTicker(t entity.Ticker) ([]entity.AverageResult, error)
+}
+
+// CandleBuilder aggregates the tickers of a product into bars.
+type CandleBuilder interface {
+	// Add returns the bar closed by the ticker, if any.
+	Add(t entity.Ticker) (entity.Candle, bool)
+	// Flush returns the bar being built, if any, and closes it.
+	Flush() (entity.Candle, bool)
+}
+
+// TickerFilter decides which tickers of a product are processed.
+type TickerFilter interface {
+	// Check returns the reason why the ticker must be dropped or nil if the ticker is accepted.
+	Check(t entity.Ticker) error
+}
+
+// AverageFilter is implemented by the filters which compare the tickers to the current vwap of their product.
+type AverageFilter interface {
+	// SetAverage is called after each accepted ticker with the exact average of the first vwap horizon of the product.
+	SetAverage(avg entity.Decimal)
+}
+
+// AuditWriter writes the tickers dropped by the filters.
+type AuditWriter interface {
+	WriteRejection(r entity.Rejection) error
 }
 
 type OutputWriter interface {
 	Write(r entity.AverageResult) error
+	WriteCandle(c entity.Candle) error
+	WriteCrossRate(c entity.CrossRate) error
+	WriteArbitrage(a entity.ArbitrageAlert) error
+	WriteIndicator(r entity.IndicatorResult) error
+	WriteConsolidated(r entity.ConsolidatedResult) error
 }
 
 type AvgManager struct {
 	doneCh chan chan interface{}
 
 	outWriter OutputWriter
+	// auditWriter -- if set, the tickers dropped by the filters are written to it
+	auditWriter AuditWriter
 	// avgCurrencyCalculators holds the avg calculators.
+	// the key is the product id. A product can have several calculators (e.g. vwap and twap).
+	avgCurrencyCalculators map[string][]PairAvgCalculator
+	// indicators holds the indicators.
+	// the key is the product id
+	indicators map[string][]compute.Indicator
+	// candleBuilders holds the candle builders.
+	// the key is the product id
+	candleBuilders map[string][]CandleBuilder
+	// heartBeatSequences holds the sequence of the last heartbeat of each product.
 	// the key is the product id
-	avgCurrencyCalculators map[string]PairAvgCalculator
+	heartBeatSequences map[string]int64
+	// dedups holds the last trade ids of each product.
+	// the key is the product id
+	dedups map[string]*tradeDedup
+	// gapTrackers holds the gaps in the trade ids of each product.
+	// the key is the product id
+	gapTrackers map[string]*gapTracker
+	// filters holds the ticker filters applied before the calculators and the candle builders.
+	// the key is the product id
+	filters map[string][]TickerFilter
+	// rejected -- number of tickers of each product dropped by the filters. Guarded by mu as it is read by the queries.
+	rejected map[string]int
+	// syntheticPairs -- pairs implied by the vwaps of other pairs
+	syntheticPairs []SyntheticPair
+	// consolidatedPairs -- windows of the consolidated pairs
+	consolidatedPairs []*consolidatedPair
+	// consolidatedVenues holds the venues of the consolidated pairs fed by each trade stream of a venue.
+	// the key is the name of the venue and the product id
+	consolidatedVenues map[venueKey][]consolidatedVenue
+	// venueDedups holds the last trade ids of each trade stream of a venue.
+	venueDedups map[venueKey]*tradeDedup
+	// arbitrageMonitors -- directions of the arbitrage cycles
+	arbitrageMonitors []*arbitrageMonitor
+	// latestAverages holds the last vwap of each horizon of each product used by the synthetic pairs and the arbitrage cycles
+	latestAverages map[averageKey]latestAverage
+	// reorderBuffers holds the messages of the products configured with a reorder buffer.
+	// the key is the product id
+	reorderBuffers map[string]*reorderBuffer
+	// checkpointStore -- if set, the state of the calculators is saved to it
+	checkpointStore CheckpointStore
+	// checkpointInterval -- period of the checkpoints. Zero means only on shutdown.
+	checkpointInterval time.Duration
+
+	// mu guards statuses, rejected, the drop counters of dedups and the gaps of gapTrackers which are written by the processing goroutine and read by the queries
+	mu sync.RWMutex
+	// statuses holds the last results of each product for the queries.
+	// the key is the product id
+	statuses map[string]*pairStatus
 }
 
+// reorderFlushInterval is the period at which the reorder buffers with a delay are checked.
+const reorderFlushInterval = 100 * time.Millisecond
+
 func NewAvgManager(o OutputWriter) *AvgManager {
 	avgManager := &AvgManager{
 		doneCh:                 make(chan chan interface{}, 1),
 		outWriter:              o,
-		avgCurrencyCalculators: make(map[string]PairAvgCalculator),
+		avgCurrencyCalculators: make(map[string][]PairAvgCalculator),
+		indicators:             make(map[string][]compute.Indicator),
+		candleBuilders:         make(map[string][]CandleBuilder),
+		dedups:                 make(map[string]*tradeDedup),
+		heartBeatSequences:     make(map[string]int64),
+		gapTrackers:            make(map[string]*gapTracker),
+		reorderBuffers:         make(map[string]*reorderBuffer),
+		filters:                make(map[string][]TickerFilter),
+		rejected:               make(map[string]int),
+		latestAverages:         make(map[averageKey]latestAverage),
+		consolidatedVenues:     make(map[venueKey][]consolidatedVenue),
+		venueDedups:            make(map[venueKey]*tradeDedup),
+		statuses:               make(map[string]*pairStatus),
 	}
 
 	return avgManager
 }
 
+// AddAvgCalculator adds a calculator to the product. Every message of the product is processed by all its calculators.
 func (a *AvgManager) AddAvgCalculator(productID string, c PairAvgCalculator) {
-	a.avgCurrencyCalculators[productID] = c
+	a.avgCurrencyCalculators[productID] = append(a.avgCurrencyCalculators[productID], c)
+	a.addProduct(productID)
+}
+
+// AddIndicator adds an indicator to the product. Every message of the product is processed by all its indicators
+// after its calculators. The indicators are run in the order they were added.
+func (a *AvgManager) AddIndicator(productID string, i compute.Indicator) {
+	a.indicators[productID] = append(a.indicators[productID], i)
+	a.addProduct(productID)
+}
+
+// addProduct sets up the trade dedup and the gap tracker of a product the first time a calculator or an indicator is added to it.
+func (a *AvgManager) addProduct(productID string) {
+	if _, found := a.dedups[productID]; !found {
+		a.dedups[productID] = newTradeDedup(DefaultTradeDedupSize)
+		a.gapTrackers[productID] = &gapTracker{}
+	}
+}
+
+// AddCandleBuilder adds a candle builder to the product. It is fed with every ticker of the product.
+func (a *AvgManager) AddCandleBuilder(productID string, b CandleBuilder) {
+	a.candleBuilders[productID] = append(a.candleBuilders[productID], b)
+}
+
+// AddFilter adds a filter to the product. A ticker is processed only if all the filters of its product accept it.
+// The filters are applied in the order they were added, after the reorder buffer.
+func (a *AvgManager) AddFilter(productID string, f TickerFilter) {
+	a.filters[productID] = append(a.filters[productID], f)
+}
+
+// SetAuditWriter sets the output of the tickers dropped by the filters.
+func (a *AvgManager) SetAuditWriter(w AuditWriter) {
+	a.auditWriter = w
 }
 
 // Start starts the avg manager.
@@ -48,6 +177,25 @@ func (a *AvgManager) Start(ctx context.Context, inputCh <-chan interface{}) {
 	logger := log.GetLogger()
 
 	go func() {
+		// the reorder buffers with a delay are checked periodically in case no message arrives
+		var flushCh <-chan time.Time
+
+		if a.hasReorderDelay() {
+			flushTicker := time.NewTicker(reorderFlushInterval)
+			defer flushTicker.Stop()
+
+			flushCh = flushTicker.C
+		}
+
+		var checkpointCh <-chan time.Time
+
+		if a.checkpointStore != nil && a.checkpointInterval > 0 {
+			checkpointTicker := time.NewTicker(a.checkpointInterval)
+			defer checkpointTicker.Stop()
+
+			checkpointCh = checkpointTicker.C
+		}
+
 		for {
 			select {
 			case msg := <-inputCh:
@@ -55,41 +203,54 @@ func (a *AvgManager) Start(ctx context.Context, inputCh <-chan interface{}) {
 				case entity.HeartBeat:
 					logger.Debugf("heart beat received: %+v", v)
 
-					c, found := a.avgCurrencyCalculators[v.ProductID]
-					if !found {
+					if _, found := a.dedups[v.ProductID]; !found {
 						logger.Errorf("received heart beat for a product that does not exists: %s", v.ProductID)
 
 						continue
 					}
-					c.ProcessHeartBeat(v)
+
+					a.receive(v.ProductID, v, v.Sequence, true)
 				case entity.Ticker:
 					logger.Debugf("ticker received: %+v", v)
 
-					c, found := a.avgCurrencyCalculators[v.ProductID]
-					if !found {
+					if len(v.Venue) > 0 {
+						a.receiveVenue(v)
+
+						continue
+					}
+
+					if _, found := a.dedups[v.ProductID]; !found {
 						logger.Errorf("received ticker for a product that does not exists: %s", v.ProductID)
 
 						continue
 					}
 
-					avg, totalPoints, err := c.ProcessTicker(v)
-					if err != nil {
-						logger.Errorf("cannot compute average: %+v", err)
-					} else {
-						err := a.outWriter.Write(entity.AverageResult{
-							ProductID:   v.ProductID,
-							Average:     avg,
-							Timestamp:   time.Now(),
-							TotalPoints: totalPoints,
-						})
-						if err != nil {
-							log.GetLogger().Warningf("cannot write to output: %+v", err)
-						}
+					if !a.isNewTrade(v) {
+						continue
 					}
+
+					a.receive(v.ProductID, v, v.Sequence, false)
 				default:
 					log.GetLogger().Warningf("cannot cast received message: %+v", msg)
 				}
+			case now := <-flushCh:
+				for _, b := range a.reorderBuffers {
+					a.dispatch(b.Release(now)...)
+				}
+			case <-checkpointCh:
+				a.saveCheckpoint()
 			case retCh := <-a.doneCh:
+				// process the messages still held before returning
+				for _, b := range a.reorderBuffers {
+					a.dispatch(b.Flush()...)
+				}
+
+				a.flushCandles()
+
+				if a.checkpointStore != nil {
+					a.saveCheckpoint()
+				}
+
 				retCh <- struct{}{}
 				return
 			case err := <-ctx.Done():
@@ -100,6 +261,275 @@ func (a *AvgManager) Start(ctx context.Context, inputCh <-chan interface{}) {
 	}()
 }
 
+// SetReorderBuffer holds the messages of the product in a buffer which releases them in sequence order.
+// A message is released when more than size messages are held or after delay. A zero size or delay means no limit.
+// Both zero disables the buffer.
+func (a *AvgManager) SetReorderBuffer(productID string, size int, delay time.Duration) {
+	if size <= 0 && delay <= 0 {
+		delete(a.reorderBuffers, productID)
+
+		return
+	}
+
+	a.reorderBuffers[productID] = newReorderBuffer(size, delay)
+}
+
+// receive processes the message at once or, if the product has a reorder buffer, once the buffer releases it.
+func (a *AvgManager) receive(productID string, msg interface{}, sequence int64, heartbeat bool) {
+	b, found := a.reorderBuffers[productID]
+	if !found {
+		a.dispatch(msg)
+
+		return
+	}
+
+	now := time.Now()
+
+	b.Push(msg, sequence, heartbeat, now)
+	a.dispatch(b.Release(now)...)
+}
+
+// dispatch processes the messages by the calculators and candle builders of their product.
+func (a *AvgManager) dispatch(msgs ...interface{}) {
+	for _, msg := range msgs {
+		switch v := msg.(type) {
+		case entity.HeartBeat:
+			a.heartBeatSequences[v.ProductID] = v.Sequence
+
+			for _, c := range a.avgCurrencyCalculators[v.ProductID] {
+				c.ProcessHeartBeat(v)
+			}
+
+			for _, i := range a.indicators[v.ProductID] {
+				i.ProcessHeartBeat(v)
+			}
+		case entity.Ticker:
+			if a.isStale(v) {
+				continue
+			}
+
+			a.trackTrade(v)
+
+			if !a.accept(v) {
+				continue
+			}
+
+			for _, c := range a.avgCurrencyCalculators[v.ProductID] {
+				a.processTicker(c, v)
+			}
+
+			a.updateFilterAverage(v.ProductID)
+
+			for _, i := range a.indicators[v.ProductID] {
+				a.processIndicator(i, v)
+			}
+
+			a.writeCrossRates(v.ProductID)
+			a.checkArbitrage(v.ProductID)
+			a.buildCandles(v)
+		}
+	}
+}
+
+// isStale returns true if the ticker arrived after a heartbeat of its product with a greater sequence.
+// The calculators would reject it so it is dropped before reaching the indicators and the candle builders.
+// Tickers without sequence are never stale.
+func (a *AvgManager) isStale(t entity.Ticker) bool {
+	last := a.heartBeatSequences[t.ProductID]
+	if t.Sequence == 0 || t.Sequence >= last {
+		return false
+	}
+
+	log.GetLogger().Warningf("ticker %+v dropped: sequence %d received after heartbeat %d", t, t.Sequence, last)
+
+	return true
+}
+
+// accept returns true if all the filters of the product accept the ticker.
+// The ticker dropped by a filter is written to the audit output, if any. Otherwise it is logged.
+func (a *AvgManager) accept(t entity.Ticker) bool {
+	for _, f := range a.filters[t.ProductID] {
+		err := f.Check(t)
+		if err == nil {
+			continue
+		}
+
+		a.mu.Lock()
+		a.rejected[t.ProductID]++
+		a.mu.Unlock()
+
+		if a.auditWriter == nil {
+			log.GetLogger().Warningf("ticker %+v dropped: %v", t, err)
+
+			return false
+		}
+
+		log.GetLogger().Debugf("ticker %+v dropped: %v", t, err)
+
+		if err := a.auditWriter.WriteRejection(entity.Rejection{Ticker: t, Reason: err.Error(), Timestamp: time.Now()}); err != nil {
+			log.GetLogger().Warningf("cannot write rejection to audit output: %+v", err)
+		}
+
+		return false
+	}
+
+	return true
+}
+
+func (a *AvgManager) hasReorderDelay() bool {
+	for _, b := range a.reorderBuffers {
+		if b.delay > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DroppedTrades returns the number of tickers of the product dropped because their trade was already processed.
+// It is safe to call from any goroutine while the manager is running.
+func (a *AvgManager) DroppedTrades(productID string) int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if d, found := a.dedups[productID]; found {
+		return d.Dropped()
+	}
+
+	return 0
+}
+
+// RejectedTrades returns the number of tickers of the product dropped by the filters.
+// It is safe to call from any goroutine while the manager is running.
+func (a *AvgManager) RejectedTrades(productID string) int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.rejected[productID]
+}
+
+// updateFilterAverage passes the exact average of the first vwap horizon of the product to its filters implementing AverageFilter.
+// It runs in the goroutine of the manager which is the only one writing the results.
+func (a *AvgManager) updateFilterAverage(productID string) {
+	s, found := a.statuses[productID]
+	if !found {
+		return
+	}
+
+	for _, r := range s.results {
+		if r.Method != entity.VWAP {
+			continue
+		}
+
+		for _, f := range a.filters[productID] {
+			if af, ok := f.(AverageFilter); ok {
+				af.SetAverage(r.ExactAverage)
+			}
+		}
+
+		return
+	}
+}
+
+// isNewTrade returns false if the trade of the ticker was already processed.
+// Tickers without trade id are always processed.
+func (a *AvgManager) isNewTrade(t entity.Ticker) bool {
+	if t.TradeID == 0 {
+		return true
+	}
+
+	d := a.dedups[t.ProductID]
+
+	a.mu.Lock()
+	isNew := d.Add(t.TradeID)
+	dropped := d.Dropped()
+	a.mu.Unlock()
+
+	if isNew {
+		return true
+	}
+
+	log.GetLogger().Warningf("duplicated trade %d of %s dropped. total dropped: %d", t.TradeID, t.ProductID, dropped)
+
+	return false
+}
+
+// processTicker computes the averages of the ticker and writes them to the output.
+func (a *AvgManager) processTicker(c PairAvgCalculator, t entity.Ticker) {
+	results, err := c.ProcessTicker(t)
+	if err != nil {
+		log.GetLogger().Errorf("cannot compute average: %+v", err)
+
+		return
+	}
+
+	now := time.Now()
+	for i := range results {
+		results[i].Timestamp = now
+	}
+
+	a.storeResults(t.ProductID, results, now)
+
+	for _, r := range results {
+		a.storeAverage(r, t)
+
+		if err := a.outWriter.Write(r); err != nil {
+			log.GetLogger().Warningf("cannot write to output: %+v", err)
+		}
+	}
+}
+
+// processIndicator computes the values of the indicator and writes them to the output.
+func (a *AvgManager) processIndicator(i compute.Indicator, t entity.Ticker) {
+	values, err := i.ProcessTicker(t)
+	if err != nil {
+		log.GetLogger().Errorf("cannot compute indicator %s: %+v", i.Name(), err)
+
+		return
+	}
+
+	r := entity.IndicatorResult{
+		ProductID: t.ProductID,
+		Indicator: i.Name(),
+		Timestamp: time.Now(),
+		Values:    values,
+	}
+
+	if err := a.outWriter.WriteIndicator(r); err != nil {
+		log.GetLogger().Warningf("cannot write indicator to output: %+v", err)
+	}
+}
+
+// buildCandles adds the ticker to the candle builders of its product and writes the closed bars to the output.
+func (a *AvgManager) buildCandles(t entity.Ticker) {
+	for _, b := range a.candleBuilders[t.ProductID] {
+		candle, closed := b.Add(t)
+		if !closed {
+			continue
+		}
+
+		if err := a.outWriter.WriteCandle(candle); err != nil {
+			log.GetLogger().Warningf("cannot write candle to output: %+v", err)
+		}
+	}
+}
+
+// flushCandles writes the bars being built to the output. It is called on shutdown so the last bar of each product is not lost.
+func (a *AvgManager) flushCandles() {
+	for _, builders := range a.candleBuilders {
+		for _, b := range builders {
+			candle, found := b.Flush()
+			if !found {
+				continue
+			}
+
+			if err := a.outWriter.WriteCandle(candle); err != nil {
+				log.GetLogger().Warningf("cannot write candle to output: %+v", err)
+			}
+		}
+	}
+}
+
 // Shutdown close the avg manager.
 // Block until goroutine returned.
 func (a *AvgManager) Shutdown() {
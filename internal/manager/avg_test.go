@@ -2,11 +2,14 @@ package manager_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/tupyy/vwap/internal/compute"
 	"github.com/tupyy/vwap/internal/entity"
 	"github.com/tupyy/vwap/internal/manager"
 )
@@ -23,7 +26,7 @@ func TestAvgManager(t *testing.T) {
 
 	// add one heartbeat and one ticker
 	inputCh <- entity.HeartBeat{ProductID: "id", Sequence: 1}
-	inputCh <- entity.Ticker{ProductID: "id", Price: 1}
+	inputCh <- entity.Ticker{ProductID: "id", Price: entity.DecimalFromInt(1)}
 
 	<-time.After(1 * time.Second)
 	assert.Equal(t, 1, pairMock.HeartbeatCallCount, "should have one heart beat")
@@ -31,11 +34,11 @@ func TestAvgManager(t *testing.T) {
 
 	// assert if the avg was wrote on writer
 	assert.Equal(t, 1, writerMock.WriteCallCount, "should have one call")
-	assert.Equal(t, float64(1), writerMock.Avg, "should have avg = 1")
+	assert.Equal(t, entity.DecimalFromInt(1), writerMock.Avg, "should have avg = 1")
 
 	// push one message of another product
 	inputCh <- entity.HeartBeat{ProductID: "unkown_product", Sequence: 1}
-	inputCh <- entity.Ticker{ProductID: "unkown_product", Price: 1}
+	inputCh <- entity.Ticker{ProductID: "unkown_product", Price: entity.DecimalFromInt(1)}
 
 	<-time.After(1 * time.Second)
 	assert.Equal(t, 1, pairMock.HeartbeatCallCount, "should have one heart beat")
@@ -58,6 +61,653 @@ func TestAvgManager(t *testing.T) {
 	}
 }
 
+func TestAvgManagerSeveralCalculators(t *testing.T) {
+	writerMock := &outputWriter{}
+	vwapMock := &pairMockCalculator{}
+	twapMock := &pairMockCalculator{}
+
+	avgM := manager.NewAvgManager(writerMock)
+	avgM.AddAvgCalculator("id", vwapMock)
+	avgM.AddAvgCalculator("id", twapMock)
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	inputCh <- entity.HeartBeat{ProductID: "id", Sequence: 1}
+	inputCh <- entity.Ticker{ProductID: "id", Price: entity.DecimalFromInt(1)}
+
+	avgM.Shutdown()
+
+	assert.Equal(t, 1, vwapMock.HeartbeatCallCount, "should have one heart beat")
+	assert.Equal(t, 1, twapMock.HeartbeatCallCount, "should have one heart beat")
+	assert.Equal(t, 1, vwapMock.TickerCallCount, "should have one ticker")
+	assert.Equal(t, 1, twapMock.TickerCallCount, "should have one ticker")
+	assert.Equal(t, 2, writerMock.WriteCallCount, "should have one write per calculator")
+}
+
+func TestAvgManagerCandles(t *testing.T) {
+	writerMock := &outputWriter{}
+
+	avgM := manager.NewAvgManager(writerMock)
+	avgM.AddAvgCalculator("id", &pairMockCalculator{})
+	avgM.AddCandleBuilder("id", compute.NewCandleBuilder(time.Minute))
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+
+	inputCh <- entity.Ticker{ProductID: "id", Price: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1), Timestamp: start}
+	inputCh <- entity.Ticker{ProductID: "id", Price: entity.DecimalFromInt(2), Volume: entity.DecimalFromInt(1), Timestamp: start.Add(time.Minute)}
+
+	avgM.Shutdown()
+
+	assert.Len(t, writerMock.Candles, 2, "the second ticker should close the first bar and the second bar should be flushed on shutdown")
+	assert.Equal(t, start, writerMock.Candles[0].Start)
+	assert.Equal(t, 1, writerMock.Candles[0].TradeCount)
+	assert.Equal(t, start.Add(time.Minute), writerMock.Candles[1].Start)
+}
+
+func TestAvgManagerStaleTickers(t *testing.T) {
+	writerMock := &outputWriter{}
+	pairMock := &pairMockCalculator{}
+
+	avgM := manager.NewAvgManager(writerMock)
+	avgM.AddAvgCalculator("id", pairMock)
+	avgM.AddCandleBuilder("id", compute.NewCandleBuilder(time.Minute))
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+
+	inputCh <- entity.HeartBeat{ProductID: "id", Sequence: 5}
+	// the ticker 4 arrives after the heartbeat 5
+	inputCh <- entity.Ticker{ProductID: "id", Sequence: 4, Price: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1), Timestamp: start}
+	inputCh <- entity.Ticker{ProductID: "id", Sequence: 6, Price: entity.DecimalFromInt(2), Volume: entity.DecimalFromInt(1), Timestamp: start}
+
+	avgM.Shutdown()
+
+	assert.Equal(t, []int64{5, 6}, pairMock.Sequences, "the stale ticker should be dropped")
+	assert.Len(t, writerMock.Candles, 1)
+	assert.Equal(t, 1, writerMock.Candles[0].TradeCount, "the stale ticker should not be part of the bar")
+	assert.Equal(t, "2", writerMock.Candles[0].Open.String())
+}
+
+func TestAvgManagerTradeGaps(t *testing.T) {
+	writerMock := &outputWriter{}
+	calc := compute.NewAvgCalculator(2)
+	calc.SetMarkDegraded(true)
+
+	avgM := manager.NewAvgManager(writerMock)
+	avgM.AddAvgCalculator("id", calc)
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	// the trades 3 to 4 and 6 are lost. The sequences of the tickers are not consecutive.
+	for i, tradeID := range []int64{1, 2, 5, 7, 8, 9} {
+		inputCh <- entity.Ticker{ProductID: "id", Sequence: int64(10 * (i + 1)), TradeID: tradeID, Price: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1)}
+	}
+
+	avgM.Shutdown()
+
+	status, found := avgM.Latest("id")
+	assert.True(t, found)
+	assert.Equal(t, entity.TradeGaps{Count: 2, Missing: 3, Ranges: []entity.TradeGap{{From: 3, To: 4}, {From: 6, To: 6}}}, status.Gaps)
+
+	// the window of 2 points has turned over 2 points after the last gap
+	r, _ := avgM.LatestResult("id", entity.VWAP, "2")
+	assert.False(t, r.Degraded)
+}
+
+func TestAvgManagerLateTradeFillsGap(t *testing.T) {
+	avgM := manager.NewAvgManager(&outputWriter{})
+	avgM.AddAvgCalculator("id", &pairMockCalculator{})
+	avgM.SetReorderBuffer("id", 1, 0)
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	// the trade 3 is released after the trade 5 which opened the gap 3 to 4
+	for _, tradeID := range []int64{1, 2, 5, 6, 3} {
+		inputCh <- entity.Ticker{ProductID: "id", Sequence: tradeID, TradeID: tradeID, Price: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1)}
+	}
+
+	avgM.Shutdown()
+
+	status, _ := avgM.Latest("id")
+	assert.Equal(t, entity.TradeGaps{Count: 1, Missing: 1, Ranges: []entity.TradeGap{{From: 4, To: 4}}}, status.Gaps)
+}
+
+func TestAvgManagerDuplicatedTrades(t *testing.T) {
+	writerMock := &outputWriter{}
+	pairMock := &pairMockCalculator{}
+
+	avgM := manager.NewAvgManager(writerMock)
+	avgM.AddAvgCalculator("id", pairMock)
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	inputCh <- entity.Ticker{ProductID: "id", TradeID: 1, Price: entity.DecimalFromInt(1)}
+	inputCh <- entity.Ticker{ProductID: "id", TradeID: 2, Price: entity.DecimalFromInt(2)}
+	// duplicated frame
+	inputCh <- entity.Ticker{ProductID: "id", TradeID: 2, Price: entity.DecimalFromInt(2)}
+
+	// the counter can be read while the manager is running
+	assert.LessOrEqual(t, avgM.DroppedTrades("id"), 1)
+
+	avgM.Shutdown()
+
+	assert.Equal(t, 2, pairMock.TickerCallCount, "the duplicated trade should be dropped")
+	assert.Equal(t, 1, avgM.DroppedTrades("id"))
+	assert.Equal(t, 0, avgM.DroppedTrades("unknown_product"))
+}
+
+func TestAvgManagerReorderBuffer(t *testing.T) {
+	writerMock := &outputWriter{}
+	pairMock := &pairMockCalculator{}
+
+	avgM := manager.NewAvgManager(writerMock)
+	avgM.AddAvgCalculator("id", pairMock)
+	avgM.SetReorderBuffer("id", 2, 0)
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	// the ticker 2 arrives after the heartbeat 2
+	inputCh <- entity.Ticker{ProductID: "id", Sequence: 1, Price: entity.DecimalFromInt(1)}
+	inputCh <- entity.HeartBeat{ProductID: "id", Sequence: 2}
+	inputCh <- entity.Ticker{ProductID: "id", Sequence: 2, Price: entity.DecimalFromInt(2)}
+	inputCh <- entity.Ticker{ProductID: "id", Sequence: 3, Price: entity.DecimalFromInt(3)}
+
+	avgM.Shutdown()
+
+	assert.Equal(t, []int64{1, 2, 2, 3}, pairMock.Sequences, "the messages should be processed in sequence order")
+	assert.Equal(t, 3, writerMock.WriteCallCount, "the buffer should be flushed on shutdown")
+}
+
+func TestAvgManagerFilters(t *testing.T) {
+	writerMock := &outputWriter{}
+	pairMock := &pairMockCalculator{}
+	filter := compute.NewVolumeFilter(entity.MustParseDecimal("0.01"))
+
+	avgM := manager.NewAvgManager(writerMock)
+	avgM.AddAvgCalculator("id", pairMock)
+	avgM.AddFilter("id", filter)
+	avgM.SetAuditWriter(writerMock)
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	inputCh <- entity.Ticker{ProductID: "id", Price: entity.DecimalFromInt(1), Volume: entity.MustParseDecimal("0.001")}
+	inputCh <- entity.Ticker{ProductID: "id", Price: entity.DecimalFromInt(2), Volume: entity.MustParseDecimal("0.01")}
+
+	avgM.Shutdown()
+
+	assert.Equal(t, 1, pairMock.TickerCallCount, "the dust trade should be dropped")
+	assert.Equal(t, 1, filter.Rejected())
+	assert.Len(t, writerMock.Rejections, 1, "the dust trade should be written to the audit output")
+	assert.ErrorIs(t, filter.Check(writerMock.Rejections[0].Ticker), compute.ErrVolumeTooLow)
+}
+
+func TestAvgManagerFiltersTradeGaps(t *testing.T) {
+	calc := compute.NewAvgCalculator(10)
+	calc.SetMarkDegraded(true)
+
+	avgM := manager.NewAvgManager(&outputWriter{})
+	avgM.AddAvgCalculator("id", calc)
+	avgM.AddFilter("id", compute.NewVolumeFilter(entity.MustParseDecimal("0.01")))
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	// the dust trade 2 is dropped by the filter but it was received
+	inputCh <- entity.Ticker{ProductID: "id", TradeID: 1, Price: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1)}
+	inputCh <- entity.Ticker{ProductID: "id", TradeID: 2, Price: entity.DecimalFromInt(1), Volume: entity.MustParseDecimal("0.001")}
+	inputCh <- entity.Ticker{ProductID: "id", TradeID: 3, Price: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1)}
+
+	avgM.Shutdown()
+
+	status, found := avgM.Latest("id")
+	assert.True(t, found)
+	assert.Zero(t, status.Gaps.Count, "the dropped ticker should not open a gap")
+	assert.False(t, status.Results[0].Degraded)
+	assert.Equal(t, 2, status.Results[0].TotalPoints)
+	assert.Equal(t, 1, status.RejectedTrades)
+}
+
+func TestAvgManagerOutlierFilterAverage(t *testing.T) {
+	avgM := manager.NewAvgManager(&outputWriter{})
+	avgM.AddAvgCalculator("id", compute.NewAvgCalculator(10))
+	avgM.AddFilter("id", compute.NewOutlierFilter(10, 0, 10))
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	// the percent check compares the tickers to the vwap of the pair (100)
+	for _, p := range []int64{100, 100, 120, 105} {
+		inputCh <- entity.Ticker{ProductID: "id", Price: entity.DecimalFromInt(p), Volume: entity.DecimalFromInt(1)}
+	}
+
+	avgM.Shutdown()
+
+	status, found := avgM.Latest("id")
+	assert.True(t, found)
+	assert.Equal(t, 3, status.Results[0].TotalPoints)
+	assert.Equal(t, 1, status.RejectedTrades)
+	assert.Equal(t, 1, avgM.RejectedTrades("id"))
+}
+
+func TestAvgManagerSyntheticPairs(t *testing.T) {
+	writerMock := &outputWriter{}
+
+	avgM := manager.NewAvgManager(writerMock)
+	for _, productID := range []string{"ETH-BTC", "BTC-USD", "ETH-USD"} {
+		avgM.AddAvgCalculator(productID, &pairMockCalculator{})
+	}
+
+	avgM.AddSyntheticPair(manager.SyntheticPair{ProductID: "ETH-USD", Legs: []string{"ETH-BTC", "BTC-USD"}, Horizon: "last"})
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	// no cross rate until both legs have a vwap
+	inputCh <- entity.Ticker{ProductID: "ETH-BTC", Price: entity.MustParseDecimal("0.07")}
+	inputCh <- entity.Ticker{ProductID: "BTC-USD", Price: entity.DecimalFromInt(60000)}
+	inputCh <- entity.Ticker{ProductID: "ETH-USD", Price: entity.DecimalFromInt(4180)}
+
+	avgM.Shutdown()
+
+	assert.Len(t, writerMock.CrossRates, 2, "a cross rate should be written once both legs have a vwap")
+
+	implied := writerMock.CrossRates[0]
+	assert.Equal(t, "ETH-USD", implied.ProductID)
+	assert.Equal(t, entity.DecimalFromInt(4200), implied.Implied)
+	assert.True(t, implied.Direct.IsZero(), "ETH-USD has no vwap yet")
+	assert.Len(t, implied.Legs, 2)
+
+	withDirect := writerMock.CrossRates[1]
+	assert.Equal(t, entity.DecimalFromInt(4180), withDirect.Direct)
+	assert.Equal(t, entity.DecimalFromInt(20), withDirect.Basis)
+	assert.InDelta(t, 47.85, withDirect.BasisBps, 0.01)
+}
+
+func TestAvgManagerSyntheticPairsWarmingUp(t *testing.T) {
+	writerMock := &outputWriter{}
+
+	ethBTC := compute.NewAvgCalculator(2)
+	ethBTC.SetQuoteIncrement(entity.MustParseDecimal("0.00001"))
+
+	btcUSD := compute.NewAvgCalculator(2)
+	btcUSD.SetWarmUp(compute.WarmUp{MinPoints: 2})
+
+	avgM := manager.NewAvgManager(writerMock)
+	avgM.AddAvgCalculator("ETH-BTC", ethBTC)
+	avgM.AddAvgCalculator("BTC-USD", btcUSD)
+	avgM.AddSyntheticPair(manager.SyntheticPair{ProductID: "ETH-USD", Legs: []string{"ETH-BTC", "BTC-USD"}, Horizon: "2"})
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	inputCh <- entity.Ticker{ProductID: "ETH-BTC", TradeID: 1, Price: entity.MustParseDecimal("0.070005"), Volume: entity.DecimalFromInt(1)}
+	inputCh <- entity.Ticker{ProductID: "BTC-USD", TradeID: 1, Price: entity.DecimalFromInt(60000), Volume: entity.DecimalFromInt(1)}
+	inputCh <- entity.Ticker{ProductID: "BTC-USD", TradeID: 2, Price: entity.DecimalFromInt(60000), Volume: entity.DecimalFromInt(1)}
+
+	avgM.Shutdown()
+
+	assert.Len(t, writerMock.CrossRates, 2)
+
+	// the implied vwap uses the ETH-BTC vwap before its rounding to the quote increment
+	assert.Equal(t, entity.MustParseDecimal("4200.3"), writerMock.CrossRates[0].Implied)
+	assert.True(t, writerMock.CrossRates[0].WarmingUp, "BTC-USD has only one point")
+	assert.True(t, writerMock.CrossRates[0].Legs[1].WarmingUp)
+	assert.False(t, writerMock.CrossRates[1].WarmingUp)
+}
+
+func TestAvgManagerArbitrage(t *testing.T) {
+	writerMock := &outputWriter{}
+
+	avgM := manager.NewAvgManager(writerMock)
+	for _, productID := range []string{"ETH-BTC", "BTC-USD", "ETH-USD"} {
+		avgM.AddAvgCalculator(productID, &pairMockCalculator{})
+	}
+
+	err := avgM.AddArbitrageCycle(manager.ArbitrageCycle{Currencies: []string{"USD", "BTC", "SOL"}, Horizon: "last"})
+	assert.ErrorIs(t, err, manager.ErrUnknownPair)
+
+	err = avgM.AddArbitrageCycle(manager.ArbitrageCycle{Currencies: []string{"USD", "BTC", "ETH"}, Horizon: "5m"})
+	assert.ErrorIs(t, err, manager.ErrUnknownHorizon)
+
+	err = avgM.AddArbitrageCycle(manager.ArbitrageCycle{Currencies: []string{"USD", "BTC", "USD", "ETH"}, Horizon: "last"})
+	assert.NotNil(t, err, "a repeated currency should be rejected")
+
+	err = avgM.AddArbitrageCycle(manager.ArbitrageCycle{
+		Currencies: []string{"USD", "BTC", "ETH"},
+		Horizon:    "last",
+		FeeBps:     10,
+		MinEdgeBps: 50,
+	})
+	assert.Nil(t, err)
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+
+	inputCh <- entity.Ticker{ProductID: "BTC-USD", Price: entity.DecimalFromInt(60000), Timestamp: start}
+	inputCh <- entity.Ticker{ProductID: "ETH-BTC", Price: entity.MustParseDecimal("0.07"), Timestamp: start.Add(time.Second)}
+	// USD→BTC→ETH→USD: 4300 / (60000 * 0.07) = 1.0238
+	inputCh <- entity.Ticker{ProductID: "ETH-USD", Price: entity.DecimalFromInt(4300), Timestamp: start.Add(3 * time.Second)}
+	// the edge is still above the threshold: no new alert
+	inputCh <- entity.Ticker{ProductID: "ETH-USD", Price: entity.DecimalFromInt(4310), Timestamp: start.Add(4 * time.Second)}
+
+	avgM.Shutdown()
+
+	assert.Len(t, writerMock.Arbitrages, 1, "an alert should be raised when the edge crosses the threshold")
+
+	alert := writerMock.Arbitrages[0]
+	assert.Equal(t, []string{"USD", "BTC", "ETH", "USD"}, alert.Cycle)
+	assert.Equal(t, []entity.ArbitrageLeg{
+		{ProductID: "BTC-USD", Side: entity.Buy, Price: entity.DecimalFromInt(60000), Timestamp: start},
+		{ProductID: "ETH-BTC", Side: entity.Buy, Price: entity.MustParseDecimal("0.07"), Timestamp: start.Add(time.Second)},
+		{ProductID: "ETH-USD", Side: entity.Sell, Price: entity.DecimalFromInt(4300), Timestamp: start.Add(3 * time.Second)},
+	}, alert.Legs)
+	assert.InDelta(t, 238.10, alert.GrossEdgeBps, 0.01)
+	assert.InDelta(t, 207.41, alert.EdgeBps, 0.01)
+	assert.Equal(t, 3*time.Second, alert.Skew)
+}
+
+func TestAvgManagerArbitrageWarmingUp(t *testing.T) {
+	writerMock := &outputWriter{}
+
+	avgM := manager.NewAvgManager(writerMock)
+	for _, productID := range []string{"ETH-BTC", "BTC-USD", "ETH-USD"} {
+		c := compute.NewAvgCalculator(10)
+		c.SetWarmUp(compute.WarmUp{MinPoints: 2})
+
+		avgM.AddAvgCalculator(productID, c)
+	}
+
+	err := avgM.AddArbitrageCycle(manager.ArbitrageCycle{Currencies: []string{"USD", "BTC", "ETH"}, Horizon: "10", MinEdgeBps: 50})
+	assert.Nil(t, err)
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	inputCh <- entity.Ticker{ProductID: "BTC-USD", TradeID: 1, Price: entity.DecimalFromInt(60000), Volume: entity.DecimalFromInt(1)}
+	inputCh <- entity.Ticker{ProductID: "BTC-USD", TradeID: 2, Price: entity.DecimalFromInt(60000), Volume: entity.DecimalFromInt(1)}
+	inputCh <- entity.Ticker{ProductID: "ETH-BTC", TradeID: 1, Price: entity.MustParseDecimal("0.07"), Volume: entity.DecimalFromInt(1)}
+	inputCh <- entity.Ticker{ProductID: "ETH-BTC", TradeID: 2, Price: entity.MustParseDecimal("0.07"), Volume: entity.DecimalFromInt(1)}
+	// the vwap of ETH-USD over a single trade is warming up: no alert
+	inputCh <- entity.Ticker{ProductID: "ETH-USD", TradeID: 1, Price: entity.DecimalFromInt(4300), Volume: entity.DecimalFromInt(1)}
+	inputCh <- entity.Ticker{ProductID: "ETH-USD", TradeID: 2, Price: entity.DecimalFromInt(4300), Volume: entity.DecimalFromInt(1)}
+
+	avgM.Shutdown()
+
+	assert.Len(t, writerMock.Arbitrages, 1, "the alert should be raised once all the vwaps are warm")
+	assert.Equal(t, entity.DecimalFromInt(4300), writerMock.Arbitrages[0].Legs[2].Price)
+}
+
+func TestAvgManagerIndicators(t *testing.T) {
+	writerMock := &outputWriter{}
+
+	ema, err := compute.NewIndicator("ema", "ema-3", json.RawMessage(`{"period": 3}`))
+	assert.Nil(t, err)
+
+	avgM := manager.NewAvgManager(writerMock)
+	// a pair can have only indicators
+	avgM.AddIndicator("id", ema)
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	inputCh <- entity.Ticker{ProductID: "id", TradeID: 1, Price: entity.DecimalFromInt(1)}
+	inputCh <- entity.Ticker{ProductID: "id", TradeID: 2, Price: entity.DecimalFromInt(2)}
+	// duplicated trade
+	inputCh <- entity.Ticker{ProductID: "id", TradeID: 2, Price: entity.DecimalFromInt(2)}
+
+	avgM.Shutdown()
+
+	assert.Equal(t, 0, writerMock.WriteCallCount)
+	assert.Len(t, writerMock.Indicators, 2)
+	assert.Equal(t, "ema-3", writerMock.Indicators[1].Indicator)
+	assert.Equal(t, entity.NewDecimalValue("ema", entity.MustParseDecimal("1.5")), writerMock.Indicators[1].Values[0])
+	assert.Equal(t, 1, avgM.DroppedTrades("id"))
+}
+
+func TestAvgManagerConsolidatedPairs(t *testing.T) {
+	writerMock := &outputWriter{}
+	pairMock := &pairMockCalculator{}
+
+	avgM := manager.NewAvgManager(writerMock)
+	avgM.AddAvgCalculator("BTC-USD", pairMock)
+
+	err := avgM.AddConsolidatedPair(manager.ConsolidatedPair{Symbol: "BTC", Venues: []manager.Venue{{Name: "a", ProductID: "BTC-USD"}, {Name: "a", ProductID: "BTC-USD"}}}, compute.NewCalculator(10))
+	assert.ErrorIs(t, err, manager.ErrInvalidVenue)
+
+	// the venues must trade the same base and quote currency
+	err = avgM.AddConsolidatedPair(manager.ConsolidatedPair{Symbol: "BTC", Venues: []manager.Venue{{Name: "a", ProductID: "BTC-USD"}, {Name: "b", ProductID: "BTC-USDC"}}}, compute.NewCalculator(10))
+	assert.ErrorIs(t, err, manager.ErrInvalidVenue)
+
+	err = avgM.AddConsolidatedPair(manager.ConsolidatedPair{
+		Symbol:  "BTC",
+		Horizon: "10",
+		Venues: []manager.Venue{
+			{Name: "primary", ProductID: "BTC-USD"},
+			{Name: "half", ProductID: "BTC-USD", Weight: entity.MustParseDecimal("0.5")},
+			{Name: "excluded", ProductID: "BTC-USD", Excluded: true},
+		},
+	}, compute.NewCalculator(10))
+	assert.Nil(t, err)
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	ticker := func(venue string, price, volume int64) entity.Ticker {
+		return entity.Ticker{ProductID: "BTC-USD", TradeID: 1, Price: entity.DecimalFromInt(price), Volume: entity.DecimalFromInt(volume), Venue: venue}
+	}
+
+	// the tickers of the trading pair are not merged
+	inputCh <- ticker("", 90, 1)
+	// the trade ids of the venues are independent: the trade 1 of primary is dropped only when primary sends it again
+	inputCh <- ticker("primary", 100, 2)
+	inputCh <- ticker("half", 106, 8)
+	inputCh <- ticker("primary", 100, 2)
+	inputCh <- ticker("excluded", 200, 1)
+	inputCh <- ticker("unknown", 200, 1)
+
+	avgM.Shutdown()
+
+	assert.Equal(t, 1, pairMock.TickerCallCount, "the tickers of the venues should not reach the calculators of the trading pair")
+	assert.Zero(t, avgM.DroppedTrades("BTC-USD"))
+	assert.Len(t, writerMock.Consolidated, 2)
+
+	// the volume of half is halved: (100 * 2 + 106 * 4) / 6
+	r := writerMock.Consolidated[1]
+	assert.Equal(t, "BTC", r.Symbol)
+	assert.Equal(t, entity.DecimalFromInt(104), r.Average)
+	assert.Equal(t, 2, r.TotalPoints)
+	assert.Len(t, r.Venues, 2)
+	assert.Equal(t, "half", r.Venues[0].Venue)
+	assert.Equal(t, entity.DecimalFromInt(4), r.Venues[0].Volume)
+	assert.InDelta(t, 2.0/3, r.Venues[0].Share, 1e-9)
+	assert.Equal(t, "primary", r.Venues[1].Venue)
+	assert.Equal(t, entity.DecimalFromInt(100), r.Venues[1].Average)
+}
+
+func TestAvgManagerLatest(t *testing.T) {
+	avgM := manager.NewAvgManager(&outputWriter{})
+	avgM.AddAvgCalculator("id", compute.NewTimeAvgCalculator(time.Minute))
+	avgM.AddAvgCalculator("id", compute.NewTWAPCalculator("1m0s", time.Minute))
+
+	_, found := avgM.Latest("id")
+	assert.False(t, found, "no result yet")
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+	done := make(chan struct{})
+
+	// the queries run while the tickers are processed
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 1000; i++ {
+			if status, found := avgM.Latest("id"); found {
+				assert.Len(t, status.Results, 2)
+			}
+
+			_, _ = avgM.LatestResult("id", entity.VWAP, "1m0s")
+		}
+	}()
+
+	for i := 1; i <= 100; i++ {
+		inputCh <- entity.Ticker{ProductID: "id", Sequence: int64(i), Price: entity.DecimalFromInt(int64(i)), Volume: entity.DecimalFromInt(1), Timestamp: start.Add(time.Duration(i) * time.Second)}
+	}
+
+	<-done
+	avgM.Shutdown()
+
+	status, found := avgM.Latest("id")
+	assert.True(t, found)
+	assert.Equal(t, "id", status.ProductID)
+	assert.False(t, status.LastUpdate.IsZero())
+	assert.Equal(t, entity.VWAP, status.Results[0].Method)
+	assert.Equal(t, entity.TWAP, status.Results[1].Method)
+
+	// the last minute holds the tickers 41 to 100
+	r, found := avgM.LatestResult("id", entity.VWAP, "1m0s")
+	assert.True(t, found)
+	assert.Equal(t, 60, r.Window.TotalPoints)
+	assert.Equal(t, entity.DecimalFromInt(60), r.Window.Volume)
+	assert.Equal(t, 59*time.Second, r.Window.Span())
+	assert.False(t, r.Timestamp.After(status.LastUpdate), "the twap is computed after the vwap")
+
+	_, found = avgM.LatestResult("id", entity.VWAP, "5m")
+	assert.False(t, found)
+}
+
+func TestAvgManagerCheckpoint(t *testing.T) {
+	store := &checkpointStore{}
+	start := time.Date(2021, 11, 12, 10, 0, 0, 0, time.UTC)
+
+	avgM := manager.NewAvgManager(&outputWriter{})
+	avgM.AddAvgCalculator("id", &pairMockCalculator{})
+	avgM.AddAvgCalculator("id", compute.NewAvgCalculator(10))
+	avgM.SetCheckpointStore(store, 0)
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	inputCh <- entity.Ticker{ProductID: "id", Sequence: 1, TradeID: 1, Price: entity.DecimalFromInt(1), Volume: entity.DecimalFromInt(1), Timestamp: start}
+	inputCh <- entity.Ticker{ProductID: "id", Sequence: 2, TradeID: 2, Price: entity.DecimalFromInt(2), Volume: entity.DecimalFromInt(1), Timestamp: start.Add(time.Second)}
+
+	// the checkpoint is saved on shutdown
+	avgM.Shutdown()
+	assert.NotEmpty(t, store.Data)
+
+	writerMock := &outputWriter{}
+	restored := manager.NewAvgManager(writerMock)
+	restored.AddAvgCalculator("id", &pairMockCalculator{})
+	restored.AddAvgCalculator("id", compute.NewAvgCalculator(10))
+	restored.SetCheckpointStore(store, 0)
+	assert.Nil(t, restored.Restore(time.Minute))
+
+	inputCh = make(chan interface{})
+	restored.Start(context.Background(), inputCh)
+
+	inputCh <- entity.Ticker{ProductID: "id", Sequence: 3, TradeID: 5, Price: entity.DecimalFromInt(6), Volume: entity.DecimalFromInt(1), Timestamp: start.Add(2 * time.Second)}
+
+	restored.Shutdown()
+
+	// the restored window holds the points received before the restart: (1 + 2 + 6) / 3
+	assert.Equal(t, entity.DecimalFromInt(3), writerMock.Avg)
+	assert.Equal(t, 2, store.Saves)
+
+	// the trades missed while the process was down open a gap
+	status, _ := restored.Latest("id")
+	assert.Equal(t, []entity.TradeGap{{From: 3, To: 4}}, status.Gaps.Ranges)
+
+	// the checkpoint is too old to be restored
+	tooOld := manager.NewAvgManager(&outputWriter{})
+	tooOld.AddAvgCalculator("id", compute.NewAvgCalculator(10))
+	tooOld.SetCheckpointStore(store, 0)
+
+	time.Sleep(10 * time.Millisecond)
+	assert.ErrorIs(t, tooOld.Restore(time.Millisecond), manager.ErrCheckpointTooOld)
+
+	// nothing to restore
+	empty := manager.NewAvgManager(&outputWriter{})
+	empty.SetCheckpointStore(&checkpointStore{}, 0)
+	assert.Nil(t, empty.Restore(time.Minute))
+}
+
+func TestAvgManagerCheckpointFiltersIndicatorsConsolidated(t *testing.T) {
+	store := &checkpointStore{}
+
+	newManager := func(writer *outputWriter) *manager.AvgManager {
+		ema, err := compute.NewIndicator("ema", "ema-3", json.RawMessage(`{"period": 3}`))
+		assert.Nil(t, err)
+
+		avgM := manager.NewAvgManager(writer)
+		avgM.AddAvgCalculator("BTC-USD", &pairMockCalculator{})
+		avgM.AddFilter("BTC-USD", compute.NewOutlierFilter(3, 5, 0))
+		avgM.AddIndicator("BTC-USD", ema)
+
+		err = avgM.AddConsolidatedPair(manager.ConsolidatedPair{
+			Symbol:  "BTC",
+			Horizon: "10",
+			Venues:  []manager.Venue{{Name: "usd", ProductID: "BTC-USD"}},
+		}, compute.NewCalculator(10))
+		assert.Nil(t, err)
+
+		avgM.SetCheckpointStore(store, 0)
+
+		return avgM
+	}
+
+	avgM := newManager(&outputWriter{})
+
+	inputCh := make(chan interface{})
+	avgM.Start(context.Background(), inputCh)
+
+	for i, p := range []int64{99, 100, 101} {
+		inputCh <- entity.Ticker{ProductID: "BTC-USD", TradeID: int64(i + 1), Price: entity.DecimalFromInt(p), Volume: entity.DecimalFromInt(1)}
+		inputCh <- entity.Ticker{ProductID: "BTC-USD", TradeID: int64(i + 1), Price: entity.DecimalFromInt(p), Volume: entity.DecimalFromInt(1), Venue: "usd"}
+	}
+
+	avgM.Shutdown()
+
+	writerMock := &outputWriter{}
+	restored := newManager(writerMock)
+	restored.SetAuditWriter(writerMock)
+	assert.Nil(t, restored.Restore(time.Minute))
+
+	inputCh = make(chan interface{})
+	restored.Start(context.Background(), inputCh)
+
+	// the restored reference window rejects the outlier at once
+	inputCh <- entity.Ticker{ProductID: "BTC-USD", TradeID: 4, Price: entity.DecimalFromInt(120), Volume: entity.DecimalFromInt(1)}
+	inputCh <- entity.Ticker{ProductID: "BTC-USD", TradeID: 5, Price: entity.DecimalFromInt(104), Volume: entity.DecimalFromInt(1)}
+	inputCh <- entity.Ticker{ProductID: "BTC-USD", TradeID: 5, Price: entity.DecimalFromInt(104), Volume: entity.DecimalFromInt(1), Venue: "usd"}
+
+	restored.Shutdown()
+
+	assert.Len(t, writerMock.Rejections, 1)
+
+	// the ema goes on from 100.25: 100.25 + (104 - 100.25) / 2
+	assert.Len(t, writerMock.Indicators, 1)
+	assert.Equal(t, entity.NewDecimalValue("ema", entity.MustParseDecimal("102.125")), writerMock.Indicators[0].Values[0])
+
+	// the consolidated window holds the points received before the restart: (99 + 100 + 101 + 104) / 4
+	assert.Len(t, writerMock.Consolidated, 1)
+	assert.Equal(t, entity.DecimalFromInt(101), writerMock.Consolidated[0].Average)
+	assert.Equal(t, 4, writerMock.Consolidated[0].TotalPoints)
+}
+
 /***************
 	Mocks
 ***************/
@@ -68,26 +718,60 @@ type pairMockCalculator struct {
 	HeartbeatCallCount int
 	// counts how many times ticker method was called
 	TickerCallCount int
+	// Sequences holds the sequences of the messages in the order they were processed
+	Sequences []int64
 }
 
 func (p *pairMockCalculator) ProcessHeartBeat(h entity.HeartBeat) {
 	p.Seq = h.Sequence
 	p.HeartbeatCallCount++
+	p.Sequences = append(p.Sequences, h.Sequence)
+}
+
+func (p *pairMockCalculator) Horizons() []string {
+	return []string{"last"}
 }
 
-func (p *pairMockCalculator) ProcessTicker(t entity.Ticker) (avg float64, totalPoints int, err error) {
+func (p *pairMockCalculator) ProcessTicker(t entity.Ticker) ([]entity.AverageResult, error) {
 	p.TickerCallCount++
+	p.Sequences = append(p.Sequences, t.Sequence)
 
 	if t.Sequence == 10 {
-		return 0, 0, errors.New("ticker error")
+		return nil, errors.New("ticker error")
+	}
+
+	return []entity.AverageResult{{ProductID: t.ProductID, Method: entity.VWAP, Horizon: "last", Average: t.Price, ExactAverage: t.Price, TotalPoints: 1}}, nil
+}
+
+type checkpointStore struct {
+	Data  []byte
+	Saves int
+}
+
+func (c *checkpointStore) Save(data []byte) error {
+	c.Data = data
+	c.Saves++
+
+	return nil
+}
+
+func (c *checkpointStore) Load() ([]byte, error) {
+	if c.Data == nil {
+		return nil, os.ErrNotExist
 	}
 
-	return t.Price, 1, nil
+	return c.Data, nil
 }
 
 type outputWriter struct {
 	WriteCallCount int
-	Avg            float64
+	Avg            entity.Decimal
+	Candles        []entity.Candle
+	Rejections     []entity.Rejection
+	CrossRates     []entity.CrossRate
+	Arbitrages     []entity.ArbitrageAlert
+	Indicators     []entity.IndicatorResult
+	Consolidated   []entity.ConsolidatedResult
 }
 
 func (o *outputWriter) Write(r entity.AverageResult) error {
@@ -96,3 +780,39 @@ func (o *outputWriter) Write(r entity.AverageResult) error {
 
 	return nil
 }
+
+func (o *outputWriter) WriteArbitrage(a entity.ArbitrageAlert) error {
+	o.Arbitrages = append(o.Arbitrages, a)
+
+	return nil
+}
+
+func (o *outputWriter) WriteIndicator(r entity.IndicatorResult) error {
+	o.Indicators = append(o.Indicators, r)
+
+	return nil
+}
+
+func (o *outputWriter) WriteConsolidated(r entity.ConsolidatedResult) error {
+	o.Consolidated = append(o.Consolidated, r)
+
+	return nil
+}
+
+func (o *outputWriter) WriteCrossRate(c entity.CrossRate) error {
+	o.CrossRates = append(o.CrossRates, c)
+
+	return nil
+}
+
+func (o *outputWriter) WriteRejection(r entity.Rejection) error {
+	o.Rejections = append(o.Rejections, r)
+
+	return nil
+}
+
+func (o *outputWriter) WriteCandle(c entity.Candle) error {
+	o.Candles = append(o.Candles, c)
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tupyy/vwap/internal/log"
+)
+
+// ErrCheckpointTooOld is returned by Restore when the checkpoint was saved longer ago than the max age.
+var ErrCheckpointTooOld = errors.New("checkpoint too old")
+
+// Snapshotter is implemented by the calculators whose state can be checkpointed.
+type Snapshotter interface {
+	Snapshot() (json.RawMessage, error)
+	Restore(state json.RawMessage) error
+}
+
+// CheckpointStore saves and loads the checkpoint of the manager.
+type CheckpointStore interface {
+	Save(data []byte) error
+	// Load returns an error wrapping os.ErrNotExist if no checkpoint was saved.
+	Load() ([]byte, error)
+}
+
+// checkpoint holds the state of the calculators, the filters and the indicators of each product and of the consolidated pairs.
+type checkpoint struct {
+	SavedAt time.Time `json:"saved_at"`
+	// Pairs -- the key is the product id. The states are in the order the calculators were added.
+	// The state of a calculator which cannot be checkpointed is null.
+	Pairs map[string][]json.RawMessage `json:"pairs"`
+	// Filters -- the key is the product id. The states are in the order the filters were added.
+	// The state of a filter which cannot be checkpointed (e.g. without reference window) is null.
+	Filters map[string][]json.RawMessage `json:"filters,omitempty"`
+	// Indicators -- the key is the product id. The states are in the order the indicators were added.
+	Indicators map[string][]json.RawMessage `json:"indicators,omitempty"`
+	// Consolidated -- window of each consolidated pair. The key is the symbol and the horizon of the pair.
+	Consolidated map[string]json.RawMessage `json:"consolidated,omitempty"`
+	// TradeIDs -- last trade id of each product so the trades missed while the process was down open a gap
+	TradeIDs map[string]int64 `json:"trade_ids,omitempty"`
+	// HeartBeatSequences -- sequence of the last heartbeat of each product
+	HeartBeatSequences map[string]int64 `json:"heartbeat_sequences,omitempty"`
+}
+
+// SetCheckpointStore saves the state of the calculators to the store every interval and on shutdown.
+// A zero interval saves only on shutdown.
+func (a *AvgManager) SetCheckpointStore(s CheckpointStore, interval time.Duration) {
+	a.checkpointStore = s
+	a.checkpointInterval = interval
+}
+
+// Restore loads the checkpoint from the store and restores the state of the calculators, the filters, the indicators
+// and the consolidated pairs. It must be called before Start.
+// It returns ErrCheckpointTooOld if the checkpoint is older than maxAge. A zero maxAge accepts any checkpoint.
+// Nothing is restored if no checkpoint was saved. A calculator, a filter or an indicator whose state cannot be restored starts empty.
+func (a *AvgManager) Restore(maxAge time.Duration) error {
+	if a.checkpointStore == nil {
+		return nil
+	}
+
+	data, err := a.checkpointStore.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		log.GetLogger().Infof("no checkpoint to restore")
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return fmt.Errorf("cannot decode checkpoint: %w", err)
+	}
+
+	if age := time.Since(cp.SavedAt); maxAge > 0 && age > maxAge {
+		return fmt.Errorf("%w: saved %s ago, max age %s", ErrCheckpointTooOld, age.Round(time.Second), maxAge)
+	}
+
+	for productID, id := range cp.TradeIDs {
+		if g, found := a.gapTrackers[productID]; found {
+			g.lastTradeID = id
+		}
+	}
+
+	for productID, sequence := range cp.HeartBeatSequences {
+		if _, found := a.dedups[productID]; found {
+			a.heartBeatSequences[productID] = sequence
+		}
+	}
+
+	for productID, states := range cp.Pairs {
+		calculators, found := a.avgCurrencyCalculators[productID]
+		if !found {
+			log.GetLogger().Warningf("checkpoint of %s which is not a trading pair. ignored", productID)
+
+			continue
+		}
+
+		items := make([]interface{}, 0, len(calculators))
+		for _, c := range calculators {
+			items = append(items, c)
+		}
+
+		restoreStates("calculators of "+productID, items, states)
+	}
+
+	for productID, states := range cp.Filters {
+		items := make([]interface{}, 0, len(a.filters[productID]))
+		for _, f := range a.filters[productID] {
+			items = append(items, f)
+		}
+
+		restoreStates("filters of "+productID, items, states)
+	}
+
+	for productID, states := range cp.Indicators {
+		items := make([]interface{}, 0, len(a.indicators[productID]))
+		for _, i := range a.indicators[productID] {
+			items = append(items, i)
+		}
+
+		restoreStates("indicators of "+productID, items, states)
+	}
+
+	for _, p := range a.consolidatedPairs {
+		state, found := cp.Consolidated[p.key()]
+		if !found {
+			continue
+		}
+
+		restoreStates("consolidated pair "+p.key(), []interface{}{p.calc}, []json.RawMessage{state})
+	}
+
+	log.GetLogger().Infof("checkpoint saved at %s restored", cp.SavedAt.Format(time.RFC1123Z))
+
+	return nil
+}
+
+// restoreStates restores the states into the items, in the same order. The states are ignored if their number does not match.
+func restoreStates(what string, items []interface{}, states []json.RawMessage) {
+	if len(states) != len(items) {
+		log.GetLogger().Warningf("checkpoint of the %s has %d states instead of %d. ignored", what, len(states), len(items))
+
+		return
+	}
+
+	for i, item := range items {
+		s, ok := item.(Snapshotter)
+		if !ok || states[i] == nil {
+			continue
+		}
+
+		if err := s.Restore(states[i]); err != nil {
+			log.GetLogger().Warningf("cannot restore %d of the %s: %v", i, what, err)
+		}
+	}
+}
+
+// snapshotStates returns the states of the items, in the same order. The state of an item which cannot be checkpointed is null.
+func snapshotStates(what string, items []interface{}) []json.RawMessage {
+	states := make([]json.RawMessage, len(items))
+
+	for i, item := range items {
+		s, ok := item.(Snapshotter)
+		if !ok {
+			continue
+		}
+
+		state, err := s.Snapshot()
+		if err != nil {
+			log.GetLogger().Warningf("cannot checkpoint %d of the %s: %v", i, what, err)
+
+			continue
+		}
+
+		states[i] = state
+	}
+
+	return states
+}
+
+// saveCheckpoint writes the state of the calculators, the filters, the indicators and the consolidated pairs to the store.
+// It runs in the goroutine of the manager.
+func (a *AvgManager) saveCheckpoint() {
+	cp := checkpoint{
+		SavedAt:            time.Now(),
+		Pairs:              make(map[string][]json.RawMessage, len(a.avgCurrencyCalculators)),
+		Filters:            make(map[string][]json.RawMessage, len(a.filters)),
+		Indicators:         make(map[string][]json.RawMessage, len(a.indicators)),
+		Consolidated:       make(map[string]json.RawMessage, len(a.consolidatedPairs)),
+		TradeIDs:           make(map[string]int64, len(a.gapTrackers)),
+		HeartBeatSequences: a.heartBeatSequences,
+	}
+
+	for productID, g := range a.gapTrackers {
+		if g.lastTradeID > 0 {
+			cp.TradeIDs[productID] = g.lastTradeID
+		}
+	}
+
+	for productID, calculators := range a.avgCurrencyCalculators {
+		items := make([]interface{}, 0, len(calculators))
+		for _, c := range calculators {
+			items = append(items, c)
+		}
+
+		cp.Pairs[productID] = snapshotStates("calculators of "+productID, items)
+	}
+
+	for productID, filters := range a.filters {
+		items := make([]interface{}, 0, len(filters))
+		for _, f := range filters {
+			items = append(items, f)
+		}
+
+		cp.Filters[productID] = snapshotStates("filters of "+productID, items)
+	}
+
+	for productID, indicators := range a.indicators {
+		items := make([]interface{}, 0, len(indicators))
+		for _, i := range indicators {
+			items = append(items, i)
+		}
+
+		cp.Indicators[productID] = snapshotStates("indicators of "+productID, items)
+	}
+
+	for _, p := range a.consolidatedPairs {
+		if state := snapshotStates("consolidated pair "+p.key(), []interface{}{p.calc})[0]; state != nil {
+			cp.Consolidated[p.key()] = state
+		}
+	}
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		log.GetLogger().Errorf("cannot encode checkpoint: %v", err)
+
+		return
+	}
+
+	if err := a.checkpointStore.Save(data); err != nil {
+		log.GetLogger().Errorf("cannot save checkpoint: %v", err)
+
+		return
+	}
+
+	log.GetLogger().Debugf("checkpoint saved")
+}
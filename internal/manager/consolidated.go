@@ -0,0 +1,186 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tupyy/vwap/internal/entity"
+	"github.com/tupyy/vwap/internal/log"
+)
+
+// ErrInvalidVenue is returned when a venue of a consolidated pair is misconfigured.
+var ErrInvalidVenue = errors.New("invalid venue")
+
+// ConsolidatedCalculator computes the vwap of the points of several venues merged into one window.
+type ConsolidatedCalculator interface {
+	Add(p entity.DataPoint)
+	ComputeAverage() (avg entity.Decimal, totalPoints int)
+	ComputeStdDev() entity.Decimal
+	// ComputeVenues returns the contribution of each venue to the window.
+	ComputeVenues() []entity.VenueShare
+}
+
+// Venue is the trade stream of a venue merged into a consolidated pair: the tickers received with the name of the venue and its product id.
+// They come from the feed of the venue, not from the feed of the trading pairs.
+type Venue struct {
+	// Name -- name of the venue set on its tickers and reported in the contribution shares
+	Name string
+	// ProductID -- id of the product traded on the venue
+	ProductID string
+	// Weight -- the volume of the trades of the venue is multiplied by Weight. Zero means 1.
+	Weight entity.Decimal
+	// Excluded -- if true, the trades of the venue are not merged
+	Excluded bool
+}
+
+// ConsolidatedPair defines an asset traded on several venues whose trades are merged into one window.
+type ConsolidatedPair struct {
+	// Symbol -- canonical symbol of the asset reported in the results
+	Symbol string
+	// Horizon -- name of the window reported in the results
+	Horizon string
+	Venues  []Venue
+}
+
+// consolidatedPair holds the window of a consolidated pair.
+type consolidatedPair struct {
+	ConsolidatedPair
+	calc ConsolidatedCalculator
+}
+
+// key identifies the consolidated pair in the checkpoints.
+func (p *consolidatedPair) key() string {
+	return p.Symbol + " " + p.Horizon
+}
+
+// venueKey identifies the trade stream of a venue.
+type venueKey struct {
+	venue     string
+	productID string
+}
+
+// consolidatedVenue is a venue of a consolidated pair.
+type consolidatedVenue struct {
+	pair  *consolidatedPair
+	venue Venue
+}
+
+// AddConsolidatedPair merges the tickers of the venues into the window c.
+// Each new trade of a venue writes the consolidated vwap with the contribution of each venue. The trades of a venue are
+// deduplicated on their trade id, separately from the trading pairs and from the other venues.
+// It returns ErrInvalidVenue for a venue without name, a name used twice, a negative weight or a product whose base or
+// quote currency differs from the other venues.
+// c must be a count or volume window: the trades of the venues are not ordered by timestamp.
+func (a *AvgManager) AddConsolidatedPair(p ConsolidatedPair, c ConsolidatedCalculator) error {
+	names := make(map[string]bool, len(p.Venues))
+
+	var base, quote string
+
+	for i, v := range p.Venues {
+		venueBase, venueQuote, ok := splitProductID(v.ProductID)
+		if !ok {
+			return fmt.Errorf("%w: product %s of venue %s of %s is not base-quote", ErrInvalidVenue, v.ProductID, v.Name, p.Symbol)
+		}
+
+		if i == 0 {
+			base, quote = venueBase, venueQuote
+		} else if venueBase != base || venueQuote != quote {
+			return fmt.Errorf("%w: venue %s of %s trades %s instead of %s-%s", ErrInvalidVenue, v.Name, p.Symbol, v.ProductID, base, quote)
+		}
+
+		if len(v.Name) == 0 || names[v.Name] {
+			return fmt.Errorf("%w: venues of %s must have unique names", ErrInvalidVenue, p.Symbol)
+		}
+
+		if v.Weight.Cmp(entity.Decimal{}) < 0 {
+			return fmt.Errorf("%w: weight of venue %s of %s is negative", ErrInvalidVenue, v.Name, p.Symbol)
+		}
+
+		names[v.Name] = true
+	}
+
+	pair := &consolidatedPair{ConsolidatedPair: p, calc: c}
+	a.consolidatedPairs = append(a.consolidatedPairs, pair)
+
+	for _, v := range p.Venues {
+		if v.Weight.IsZero() {
+			v.Weight = entity.DecimalFromInt(1)
+		}
+
+		key := venueKey{venue: v.Name, productID: v.ProductID}
+		a.consolidatedVenues[key] = append(a.consolidatedVenues[key], consolidatedVenue{pair: pair, venue: v})
+
+		if _, found := a.venueDedups[key]; !found {
+			a.venueDedups[key] = newTradeDedup(DefaultTradeDedupSize)
+		}
+	}
+
+	return nil
+}
+
+// receiveVenue merges the ticker of a venue into the consolidated pairs unless its trade was already received from the venue.
+func (a *AvgManager) receiveVenue(t entity.Ticker) {
+	key := venueKey{venue: t.Venue, productID: t.ProductID}
+
+	d, found := a.venueDedups[key]
+	if !found {
+		log.GetLogger().Errorf("received ticker of %s for a venue that does not exists: %s", t.ProductID, t.Venue)
+
+		return
+	}
+
+	if t.TradeID > 0 && !d.Add(t.TradeID) {
+		log.GetLogger().Warningf("duplicated trade %d of %s on %s dropped. total dropped: %d", t.TradeID, t.ProductID, t.Venue, d.Dropped())
+
+		return
+	}
+
+	a.consolidate(key, t)
+}
+
+// consolidate merges the ticker into the windows of the consolidated pairs of the venue and writes their vwaps to the output.
+func (a *AvgManager) consolidate(key venueKey, t entity.Ticker) {
+	for _, cv := range a.consolidatedVenues[key] {
+		if cv.venue.Excluded {
+			continue
+		}
+
+		volume, err := t.Volume.Mul(cv.venue.Weight)
+		if err != nil {
+			log.GetLogger().Warningf("cannot weight ticker %+v of venue %s: %v", t, cv.venue.Name, err)
+
+			continue
+		}
+
+		pair := cv.pair
+		pair.calc.Add(entity.DataPoint{Value: t.Price, Volume: volume, Side: t.Side, Timestamp: t.Timestamp, Venue: cv.venue.Name})
+
+		avg, totalPoints := pair.calc.ComputeAverage()
+
+		r := entity.ConsolidatedResult{
+			Symbol:      pair.Symbol,
+			Horizon:     pair.Horizon,
+			Timestamp:   time.Now(),
+			Average:     avg,
+			StdDev:      pair.calc.ComputeStdDev(),
+			TotalPoints: totalPoints,
+			Venues:      pair.calc.ComputeVenues(),
+		}
+
+		if err := a.outWriter.WriteConsolidated(r); err != nil {
+			log.GetLogger().Warningf("cannot write consolidated vwap to output: %+v", err)
+		}
+	}
+}
+
+// splitProductID returns the base and quote currencies of a product id such as BTC-USD.
+func splitProductID(productID string) (base, quote string, ok bool) {
+	i := strings.IndexByte(productID, '-')
+	if i <= 0 || i == len(productID)-1 {
+		return "", "", false
+	}
+
+	return productID[:i], productID[i+1:], true
+}
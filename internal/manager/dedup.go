@@ -0,0 +1,51 @@
+package manager
+
+// DefaultTradeDedupSize is the number of the last trade ids remembered for each product.
+const DefaultTradeDedupSize = 10_000
+
+// tradeDedup remembers the last trade ids of a product to drop the trades received twice
+// (e.g. after a reconnection or a duplicated frame).
+// It is bounded: when full, the oldest trade id is forgotten.
+type tradeDedup struct {
+	// seen -- trade ids in the set
+	seen map[int64]struct{}
+	// ids -- trade ids in insertion order, used as a circular buffer
+	ids []int64
+	// next -- index of the oldest trade id in ids once ids is full
+	next int
+	// dropped -- number of trades dropped as duplicates
+	dropped int
+}
+
+func newTradeDedup(size int) *tradeDedup {
+	return &tradeDedup{
+		seen: make(map[int64]struct{}, size),
+		ids:  make([]int64, 0, size),
+	}
+}
+
+// Add returns false if the trade id has already been seen. Otherwise it adds it to the set.
+func (d *tradeDedup) Add(tradeID int64) bool {
+	if _, found := d.seen[tradeID]; found {
+		d.dropped++
+
+		return false
+	}
+
+	if len(d.ids) < cap(d.ids) {
+		d.ids = append(d.ids, tradeID)
+	} else {
+		delete(d.seen, d.ids[d.next])
+		d.ids[d.next] = tradeID
+		d.next = (d.next + 1) % len(d.ids)
+	}
+
+	d.seen[tradeID] = struct{}{}
+
+	return true
+}
+
+// Dropped returns the number of trades dropped as duplicates.
+func (d *tradeDedup) Dropped() int {
+	return d.dropped
+}
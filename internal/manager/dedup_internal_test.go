@@ -0,0 +1,22 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTradeDedup(t *testing.T) {
+	d := newTradeDedup(2)
+
+	assert.True(t, d.Add(1))
+	assert.True(t, d.Add(2))
+	assert.False(t, d.Add(1), "1 should be a duplicate")
+
+	// 1 is forgotten
+	assert.True(t, d.Add(3))
+	assert.False(t, d.Add(2), "2 should still be remembered")
+	assert.True(t, d.Add(1), "1 should have been evicted")
+
+	assert.Equal(t, 2, d.Dropped())
+}
@@ -0,0 +1,133 @@
+package manager
+
+import (
+	"github.com/tupyy/vwap/internal/entity"
+	"github.com/tupyy/vwap/internal/log"
+)
+
+// maxRecordedGaps is the number of the last missing ranges kept for each product.
+const maxRecordedGaps = 100
+
+// GapMarker is implemented by the calculators which mark their results degraded after trades were lost.
+type GapMarker interface {
+	// MarkGap is called before the first ticker processed after a gap.
+	MarkGap()
+}
+
+// gapTracker detects the gaps in the trade ids of a product. The trade ids of a product are consecutive
+// so a trade id which skips ahead means the trades in between were lost.
+type gapTracker struct {
+	// lastTradeID -- greatest trade id seen. Zero before the first ticker.
+	lastTradeID int64
+	// gaps -- gaps detected so far. Guarded by the mutex of the manager as they are read by the queries.
+	gaps entity.TradeGaps
+}
+
+// Track records the gap between the last trade id and the trade id of t. It returns true if a gap was found.
+// The first ticker, the tickers without trade id and the tickers of older trades never open a gap.
+func (g *gapTracker) Track(t entity.Ticker) (entity.TradeGap, bool) {
+	last := g.lastTradeID
+	if t.TradeID <= last {
+		return entity.TradeGap{}, false
+	}
+
+	g.lastTradeID = t.TradeID
+
+	if last == 0 || t.TradeID == last+1 {
+		return entity.TradeGap{}, false
+	}
+
+	return entity.TradeGap{From: last + 1, To: t.TradeID - 1}, true
+}
+
+// record adds the gap to the statistics.
+func (g *gapTracker) record(gap entity.TradeGap) {
+	g.gaps.Count++
+	g.gaps.Missing += gap.Missing()
+
+	if len(g.gaps.Ranges) == maxRecordedGaps {
+		g.gaps.Ranges = append(g.gaps.Ranges[:0], g.gaps.Ranges[1:]...)
+	}
+
+	g.gaps.Ranges = append(g.gaps.Ranges, gap)
+}
+
+// fill removes the trade id of a late ticker from the recorded gaps. It returns true if the trade was missing.
+// A gap filled in its middle is split in two. The trades of the gaps no longer recorded are not found.
+func (g *gapTracker) fill(tradeID int64) bool {
+	for i, gap := range g.gaps.Ranges {
+		if tradeID < gap.From || tradeID > gap.To {
+			continue
+		}
+
+		g.gaps.Missing--
+
+		switch {
+		case gap.From == gap.To:
+			g.gaps.Ranges = append(g.gaps.Ranges[:i], g.gaps.Ranges[i+1:]...)
+			g.gaps.Count--
+		case tradeID == gap.From:
+			g.gaps.Ranges[i].From++
+		case tradeID == gap.To:
+			g.gaps.Ranges[i].To--
+		default:
+			g.gaps.Ranges[i].To = tradeID - 1
+
+			ranges := make([]entity.TradeGap, 0, len(g.gaps.Ranges)+1)
+			ranges = append(ranges, g.gaps.Ranges[:i+1]...)
+			ranges = append(ranges, entity.TradeGap{From: tradeID + 1, To: gap.To})
+			ranges = append(ranges, g.gaps.Ranges[i+1:]...)
+
+			if len(ranges) > maxRecordedGaps {
+				ranges = ranges[1:]
+			}
+
+			g.gaps.Ranges = ranges
+			g.gaps.Count++
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// trackTrade detects the trades of the product lost before the ticker. After a gap, the calculators of the product
+// implementing GapMarker are notified. A late ticker released by the reorder buffer after a newer one removes its trade from the gaps.
+// It runs before the filters so the dropped tickers do not open a gap.
+func (a *AvgManager) trackTrade(t entity.Ticker) {
+	g, found := a.gapTrackers[t.ProductID]
+	if !found {
+		return
+	}
+
+	if t.TradeID > 0 && t.TradeID < g.lastTradeID {
+		a.mu.Lock()
+		filled := g.fill(t.TradeID)
+		a.mu.Unlock()
+
+		if filled {
+			log.GetLogger().Debugf("%s: missing trade %d received late", t.ProductID, t.TradeID)
+		}
+
+		return
+	}
+
+	gap, found := g.Track(t)
+	if !found {
+		return
+	}
+
+	a.mu.Lock()
+	g.record(gap)
+	gaps := g.gaps
+	a.mu.Unlock()
+
+	log.GetLogger().Warningf("%s: trades %d to %d missing. gaps: %d, total missing: %d", t.ProductID, gap.From, gap.To, gaps.Count, gaps.Missing)
+
+	for _, c := range a.avgCurrencyCalculators[t.ProductID] {
+		if m, ok := c.(GapMarker); ok {
+			m.MarkGap()
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestGapTrackerFill(t *testing.T) {
+	var g gapTracker
+
+	for _, tradeID := range []int64{1, 5, 7} {
+		if gap, found := g.Track(entity.Ticker{TradeID: tradeID}); found {
+			g.record(gap)
+		}
+	}
+
+	assert.Equal(t, entity.TradeGaps{Count: 2, Missing: 4, Ranges: []entity.TradeGap{{From: 2, To: 4}, {From: 6, To: 6}}}, g.gaps)
+
+	// the middle of the first gap splits it
+	assert.True(t, g.fill(3))
+	assert.Equal(t, entity.TradeGaps{Count: 3, Missing: 3, Ranges: []entity.TradeGap{{From: 2, To: 2}, {From: 4, To: 4}, {From: 6, To: 6}}}, g.gaps)
+
+	assert.True(t, g.fill(6))
+	assert.True(t, g.fill(2))
+	assert.False(t, g.fill(2), "2 is no longer missing")
+	assert.Equal(t, entity.TradeGaps{Count: 1, Missing: 1, Ranges: []entity.TradeGap{{From: 4, To: 4}}}, g.gaps)
+}
@@ -0,0 +1,120 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+// PairStatus is the last state of a product computed by the manager.
+type PairStatus struct {
+	// ProductID -- id of the product
+	ProductID string
+	// Results -- last result of each horizon of the product, in the order the horizons produced their first result.
+	// The window stats of each horizon are in the results.
+	Results []entity.AverageResult
+	// LastUpdate -- time the last result of the product was computed
+	LastUpdate time.Time
+	// Gaps -- gaps detected in the trade ids of the product
+	Gaps entity.TradeGaps
+	// DroppedTrades -- number of tickers dropped because their trade was already processed
+	DroppedTrades int
+	// RejectedTrades -- number of tickers dropped by the filters
+	RejectedTrades int
+}
+
+// resultKey identifies a horizon of a product. A vwap and a twap horizon may have the same name.
+type resultKey struct {
+	method  entity.Method
+	horizon string
+}
+
+// pairStatus holds the last results of a product. It is guarded by the mutex of the manager.
+type pairStatus struct {
+	results    []entity.AverageResult
+	index      map[resultKey]int
+	lastUpdate time.Time
+}
+
+// Latest returns the last results of the product. It returns false if no result was computed for the product yet.
+// It is safe to call from any goroutine while the manager is running.
+func (a *AvgManager) Latest(productID string) (PairStatus, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	s, found := a.statuses[productID]
+	if !found {
+		return PairStatus{}, false
+	}
+
+	status := PairStatus{
+		ProductID: productID,
+		// the results are copied so the caller never sees them change. Their slices are never modified once the result is computed.
+		Results:    append([]entity.AverageResult(nil), s.results...),
+		LastUpdate: s.lastUpdate,
+	}
+
+	if d, found := a.dedups[productID]; found {
+		status.DroppedTrades = d.Dropped()
+	}
+
+	status.RejectedTrades = a.rejected[productID]
+
+	if g, found := a.gapTrackers[productID]; found {
+		status.Gaps = g.gaps
+		status.Gaps.Ranges = append([]entity.TradeGap(nil), g.gaps.Ranges...)
+	}
+
+	return status, true
+}
+
+// LatestResult returns the last result of the horizon of the product computed with the method.
+// It returns false if the horizon has no result yet. It is safe to call from any goroutine while the manager is running.
+func (a *AvgManager) LatestResult(productID string, method entity.Method, horizon string) (entity.AverageResult, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	s, found := a.statuses[productID]
+	if !found {
+		return entity.AverageResult{}, false
+	}
+
+	i, found := s.index[resultKey{method, horizon}]
+	if !found {
+		return entity.AverageResult{}, false
+	}
+
+	return s.results[i], true
+}
+
+// storeResults keeps the results for the queries. The final results of the closed sessions are not kept:
+// the horizon is described by the result of its current session.
+func (a *AvgManager) storeResults(productID string, results []entity.AverageResult, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, found := a.statuses[productID]
+	if !found {
+		s = &pairStatus{index: make(map[resultKey]int)}
+		a.statuses[productID] = s
+	}
+
+	for _, r := range results {
+		if r.SessionClose {
+			continue
+		}
+
+		key := resultKey{r.Method, r.Horizon}
+
+		if i, found := s.index[key]; found {
+			s.results[i] = r
+
+			continue
+		}
+
+		s.index[key] = len(s.results)
+		s.results = append(s.results, r)
+	}
+
+	s.lastUpdate = now
+}
@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"container/heap"
+	"time"
+)
+
+// reorderBuffer holds the messages of a product briefly and releases them in sequence order
+// so that slightly late tickers are processed instead of being rejected by the calculators.
+// A message is held until the buffer holds more than size messages or, for the oldest sequence, until delay has elapsed
+// since its arrival. Heartbeats go through the buffer as well so they do not overtake the tickers they follow.
+type reorderBuffer struct {
+	// size -- maximum number of messages held. Zero means no limit.
+	size int
+	// delay -- maximum time the message with the lowest sequence is held. Zero means no limit.
+	delay time.Duration
+	items reorderHeap
+}
+
+func newReorderBuffer(size int, delay time.Duration) *reorderBuffer {
+	return &reorderBuffer{
+		size:  size,
+		delay: delay,
+		items: make(reorderHeap, 0, size+1),
+	}
+}
+
+// Push adds the message with its sequence. now is the arrival time of the message.
+func (b *reorderBuffer) Push(msg interface{}, sequence int64, heartbeat bool, now time.Time) {
+	heap.Push(&b.items, reorderItem{
+		msg:       msg,
+		sequence:  sequence,
+		heartbeat: heartbeat,
+		arrival:   now,
+	})
+}
+
+// Release returns, in sequence order, the messages which must not be held any longer at now.
+func (b *reorderBuffer) Release(now time.Time) []interface{} {
+	var released []interface{}
+
+	for len(b.items) > 0 {
+		overflow := b.size > 0 && len(b.items) > b.size
+		expired := b.delay > 0 && now.Sub(b.items[0].arrival) >= b.delay
+
+		if !overflow && !expired {
+			break
+		}
+
+		released = append(released, heap.Pop(&b.items).(reorderItem).msg)
+	}
+
+	return released
+}
+
+// Flush returns all the messages held, in sequence order.
+func (b *reorderBuffer) Flush() []interface{} {
+	released := make([]interface{}, 0, len(b.items))
+
+	for len(b.items) > 0 {
+		released = append(released, heap.Pop(&b.items).(reorderItem).msg)
+	}
+
+	return released
+}
+
+type reorderItem struct {
+	msg       interface{}
+	sequence  int64
+	heartbeat bool
+	arrival   time.Time
+}
+
+// reorderHeap is a min-heap of messages ordered by sequence.
+// A heartbeat carries the sequence of the last message of the product so it comes after a ticker of the same sequence.
+type reorderHeap []reorderItem
+
+func (h reorderHeap) Len() int { return len(h) }
+
+func (h reorderHeap) Less(i, j int) bool {
+	if h[i].sequence != h[j].sequence {
+		return h[i].sequence < h[j].sequence
+	}
+
+	return !h[i].heartbeat && h[j].heartbeat
+}
+
+func (h reorderHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *reorderHeap) Push(x interface{}) {
+	*h = append(*h, x.(reorderItem))
+}
+
+func (h *reorderHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = reorderItem{}
+	*h = old[:len(old)-1]
+
+	return item
+}
@@ -0,0 +1,44 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tupyy/vwap/internal/entity"
+)
+
+func TestReorderBufferSize(t *testing.T) {
+	b := newReorderBuffer(2, 0)
+	now := time.Now()
+
+	push := func(seq int64) []interface{} {
+		b.Push(entity.Ticker{Sequence: seq}, seq, false, now)
+
+		return b.Release(now)
+	}
+
+	assert.Empty(t, push(3))
+	assert.Empty(t, push(1))
+	assert.Equal(t, []interface{}{entity.Ticker{Sequence: 1}}, push(2))
+	assert.Equal(t, []interface{}{entity.Ticker{Sequence: 2}}, push(4))
+
+	// the heartbeat of sequence 3 follows the ticker of sequence 3
+	b.Push(entity.HeartBeat{Sequence: 3}, 3, true, now)
+	assert.Equal(t, []interface{}{entity.Ticker{Sequence: 3}}, b.Release(now))
+
+	assert.Equal(t, []interface{}{entity.HeartBeat{Sequence: 3}, entity.Ticker{Sequence: 4}}, b.Flush())
+}
+
+func TestReorderBufferDelay(t *testing.T) {
+	b := newReorderBuffer(0, time.Second)
+	now := time.Now()
+
+	b.Push(entity.Ticker{Sequence: 2}, 2, false, now)
+	b.Push(entity.Ticker{Sequence: 1}, 1, false, now.Add(500*time.Millisecond))
+
+	// the lowest sequence has been held for less than the delay
+	assert.Empty(t, b.Release(now.Add(1200*time.Millisecond)))
+	assert.Equal(t, []interface{}{entity.Ticker{Sequence: 1}, entity.Ticker{Sequence: 2}}, b.Release(now.Add(1500*time.Millisecond)))
+}
@@ -0,0 +1,132 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/tupyy/vwap/internal/entity"
+	"github.com/tupyy/vwap/internal/log"
+)
+
+// SyntheticPair is a pair whose vwap is implied by the product of the vwaps of other pairs (e.g. ETH-USD via ETH-BTC and BTC-USD).
+type SyntheticPair struct {
+	// ProductID -- id of the synthetic pair. If the pair is traded as well, its vwap is compared to the implied one.
+	ProductID string
+	// Legs -- ids of the component pairs
+	Legs []string
+	// Horizon -- name of the vwap horizon of the legs used to compute the implied vwap
+	Horizon string
+}
+
+// averageKey identifies the vwap of a horizon of a product.
+type averageKey struct {
+	productID string
+	horizon   string
+}
+
+// latestAverage is the last vwap of a horizon of a product.
+type latestAverage struct {
+	// average -- the vwap before rounding to the quote increment of the product
+	average entity.Decimal
+	// timestamp -- timestamp of the ticker which produced the vwap
+	timestamp time.Time
+	warmingUp bool
+	degraded  bool
+}
+
+// AddSyntheticPair adds a synthetic pair. A cross rate is written each time a vwap of its legs or of the pair itself changes.
+func (a *AvgManager) AddSyntheticPair(s SyntheticPair) {
+	a.syntheticPairs = append(a.syntheticPairs, s)
+}
+
+// storeAverage keeps the vwap of the result for the synthetic pairs and the arbitrage cycles.
+func (a *AvgManager) storeAverage(r entity.AverageResult, t entity.Ticker) {
+	if (len(a.syntheticPairs) == 0 && len(a.arbitrageMonitors) == 0) || r.Method != entity.VWAP || r.SessionClose {
+		return
+	}
+
+	a.latestAverages[averageKey{r.ProductID, r.Horizon}] = latestAverage{
+		average:   r.ExactAverage,
+		timestamp: t.Timestamp,
+		warmingUp: r.WarmingUp,
+		degraded:  r.Degraded,
+	}
+}
+
+// writeCrossRates writes the cross rates of the synthetic pairs which depend on the product.
+func (a *AvgManager) writeCrossRates(productID string) {
+	for _, s := range a.syntheticPairs {
+		if !s.dependsOn(productID) {
+			continue
+		}
+
+		crossRate, ok := a.crossRate(s)
+		if !ok {
+			continue
+		}
+
+		if err := a.outWriter.WriteCrossRate(crossRate); err != nil {
+			log.GetLogger().Warningf("cannot write cross rate to output: %+v", err)
+		}
+	}
+}
+
+// crossRate returns the cross rate of the synthetic pair. It returns false until all the legs have a vwap.
+func (a *AvgManager) crossRate(s SyntheticPair) (entity.CrossRate, bool) {
+	crossRate := entity.CrossRate{
+		ProductID: s.ProductID,
+		Horizon:   s.Horizon,
+		Timestamp: time.Now(),
+		Legs:      make([]entity.CrossRateLeg, 0, len(s.Legs)),
+		Implied:   entity.DecimalFromInt(1),
+	}
+
+	for _, leg := range s.Legs {
+		latest, found := a.latestAverages[averageKey{leg, s.Horizon}]
+		if !found {
+			return entity.CrossRate{}, false
+		}
+
+		implied, err := crossRate.Implied.Mul(latest.average)
+		if err != nil {
+			log.GetLogger().Errorf("cannot compute the implied vwap of %s: %+v", s.ProductID, err)
+
+			return entity.CrossRate{}, false
+		}
+
+		crossRate.Implied = implied
+		crossRate.WarmingUp = crossRate.WarmingUp || latest.warmingUp
+		crossRate.Degraded = crossRate.Degraded || latest.degraded
+		crossRate.Legs = append(crossRate.Legs, entity.CrossRateLeg{
+			ProductID: leg,
+			Average:   latest.average,
+			Timestamp: latest.timestamp,
+			WarmingUp: latest.warmingUp,
+			Degraded:  latest.degraded,
+		})
+	}
+
+	if direct, found := a.latestAverages[averageKey{s.ProductID, s.Horizon}]; found && !direct.average.IsZero() {
+		crossRate.Direct = direct.average
+		crossRate.Basis = crossRate.Implied.Sub(direct.average)
+		crossRate.BasisBps = 10_000 * crossRate.Basis.Float64() / direct.average.Float64()
+		crossRate.WarmingUp = crossRate.WarmingUp || direct.warmingUp
+		crossRate.Degraded = crossRate.Degraded || direct.degraded
+	}
+
+	return crossRate, true
+}
+
+// dependsOn returns true if the cross rate changes with the vwap of the product.
+func (s SyntheticPair) dependsOn(productID string) bool {
+	if s.ProductID == productID {
+		return true
+	}
+
+	for _, leg := range s.Legs {
+		if leg == productID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,44 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// FileStore keeps the checkpoint in a local file.
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path}
+}
+
+// Save replaces the checkpoint. The data is written to a temporary file which is renamed
+// so a crash while saving never leaves a truncated checkpoint.
+func (f *FileStore) Save(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// Load returns the content of the checkpoint. The error wraps os.ErrNotExist if the file does not exist.
+func (f *FileStore) Load() ([]byte, error) {
+	return os.ReadFile(f.path)
+}
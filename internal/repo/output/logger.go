@@ -3,6 +3,7 @@ package output
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/tupyy/vwap/internal/entity"
@@ -25,8 +26,120 @@ func newWriter(dest *os.File) *Writer {
 }
 
 func (o *Writer) Write(r entity.AverageResult) error {
-	msg := fmt.Sprintf("[%s], ProductID: %s, Average: %f, Total data points: %d\n", r.Timestamp.Format(time.RFC1123Z), r.ProductID, r.Average, r.TotalPoints)
-	fmt.Fprint(o.dest, msg)
+	msg := fmt.Sprintf("[%s], ProductID: %s, Method: %s, Horizon: %s, Average: %s, StdDev: %s, Total data points: %d", r.Timestamp.Format(time.RFC1123Z), r.ProductID, r.Method, r.Horizon, r.Average, r.StdDev, r.TotalPoints)
+
+	for _, b := range r.Bands {
+		msg += fmt.Sprintf(", Band ±%gσ: [%s, %s]", b.Multiplier, b.Lower, b.Upper)
+	}
+
+	if !r.Median.IsZero() {
+		msg += fmt.Sprintf(", Median: %s", r.Median)
+	}
+
+	for _, p := range r.Percentiles {
+		msg += fmt.Sprintf(", P%g: %s", p.Percentile, p.Value)
+	}
+
+	if r.Method == entity.VWAP {
+		f := r.OrderFlow
+		msg += fmt.Sprintf(", Buy average: %s, Sell average: %s, Buy volume: %s, Sell volume: %s, Imbalance: %.4f", f.BuyAverage, f.SellAverage, f.BuyVolume, f.SellVolume, f.Imbalance)
+	}
+
+	if !r.SessionStart.IsZero() {
+		msg += fmt.Sprintf(", Session start: %s", r.SessionStart.Format(time.RFC1123Z))
+	}
+
+	if r.SessionClose {
+		msg += ", Session close"
+	}
+
+	if r.WarmingUp {
+		msg += ", Warming up"
+	}
+
+	if r.Degraded {
+		msg += ", Degraded"
+	}
+
+	fmt.Fprintln(o.dest, msg)
+
+	return nil
+}
+
+func (o *Writer) WriteCrossRate(c entity.CrossRate) error {
+	msg := fmt.Sprintf("[%s], ProductID: %s, Cross rate, Horizon: %s, Implied: %s", c.Timestamp.Format(time.RFC1123Z), c.ProductID, c.Horizon, c.Implied)
+
+	for _, l := range c.Legs {
+		msg += fmt.Sprintf(", %s: %s", l.ProductID, l.Average)
+	}
+
+	if !c.Direct.IsZero() {
+		msg += fmt.Sprintf(", Direct: %s, Basis: %s (%.2f bps)", c.Direct, c.Basis, c.BasisBps)
+	}
+
+	if c.WarmingUp {
+		msg += ", Warming up"
+	}
+
+	if c.Degraded {
+		msg += ", Degraded"
+	}
+
+	fmt.Fprintln(o.dest, msg)
+
+	return nil
+}
+
+func (o *Writer) WriteArbitrage(a entity.ArbitrageAlert) error {
+	msg := fmt.Sprintf("[%s], Arbitrage: %s, Horizon: %s", a.Timestamp.Format(time.RFC1123Z), strings.Join(a.Cycle, "→"), a.Horizon)
+
+	for _, l := range a.Legs {
+		msg += fmt.Sprintf(", %s %s: %s", l.Side, l.ProductID, l.Price)
+	}
+
+	msg += fmt.Sprintf(", Gross edge: %.2f bps, Edge after fees: %.2f bps, Skew: %s", a.GrossEdgeBps, a.EdgeBps, a.Skew)
+
+	fmt.Fprintln(o.dest, msg)
+
+	return nil
+}
+
+func (o *Writer) WriteConsolidated(r entity.ConsolidatedResult) error {
+	msg := fmt.Sprintf("[%s], Symbol: %s, Consolidated, Horizon: %s, Average: %s, StdDev: %s, Total data points: %d",
+		r.Timestamp.Format(time.RFC1123Z), r.Symbol, r.Horizon, r.Average, r.StdDev, r.TotalPoints)
+
+	for _, v := range r.Venues {
+		msg += fmt.Sprintf(", %s: %s (volume %s, %.2f%%)", v.Venue, v.Average, v.Volume, v.Share*100)
+	}
+
+	fmt.Fprintln(o.dest, msg)
+
+	return nil
+}
+
+func (o *Writer) WriteIndicator(r entity.IndicatorResult) error {
+	msg := fmt.Sprintf("[%s], ProductID: %s, Indicator: %s", r.Timestamp.Format(time.RFC1123Z), r.ProductID, r.Indicator)
+
+	for _, v := range r.Values {
+		msg += fmt.Sprintf(", %s: %s", v.Name, v)
+	}
+
+	fmt.Fprintln(o.dest, msg)
+
+	return nil
+}
+
+func (o *Writer) WriteRejection(r entity.Rejection) error {
+	t := r.Ticker
+	fmt.Fprintf(o.dest, "[%s], ProductID: %s, Rejected trade: %d, Sequence: %d, Time: %s, Price: %s, Volume: %s, Side: %s, Reason: %s\n",
+		r.Timestamp.Format(time.RFC1123Z), t.ProductID, t.TradeID, t.Sequence, t.Timestamp.Format(time.RFC3339Nano), t.Price, t.Volume, t.Side, r.Reason)
+
+	return nil
+}
+
+func (o *Writer) WriteCandle(c entity.Candle) error {
+	fmt.Fprintf(o.dest, "[%s], ProductID: %s, Candle: %s, Open: %s, High: %s, Low: %s, Close: %s, Volume: %s, VWAP: %s, Trades: %d\n",
+		c.Start.Format(time.RFC1123Z), c.ProductID, c.Interval, c.Open, c.High, c.Low, c.Close, c.Volume, c.VWAP, c.TradeCount)
 
 	return nil
 }
@@ -17,6 +17,8 @@ type WSClient struct {
 	conn io.ReadWriter
 	// TradingPairs -- list of trading pairs
 	tradingPairs []string
+	// venue -- name of the venue set on the tickers. Empty for the feed of the trading pairs.
+	venue string
 	// doneCh -- channel used to close the reader
 	doneCh chan chan interface{}
 }
@@ -29,6 +31,12 @@ func NewClient(conn io.ReadWriter, tradingPairs []string) *WSClient {
 	}
 }
 
+// SetVenue sets the name of the venue on the tickers received. The heartbeats of a venue are not subscribed:
+// the tickers of a venue only feed the consolidated pairs.
+func (c *WSClient) SetVenue(name string) {
+	c.venue = name
+}
+
 func (c *WSClient) Shutdown() {
 	log.GetLogger().Debugf("closing receiver")
 
@@ -61,15 +69,20 @@ func (c *WSClient) Receive(ctx context.Context, outputCh chan<- interface{}, err
 				var t entity.Ticker
 				err := json.Unmarshal(msg.Message, &t)
 				if err != nil {
-					logger.Errorf("%+v", err)
+					logger.Errorf("cannot decode ticker: %+v", err)
+
+					break
 				}
 
+				t.Venue = c.venue
 				outputCh <- t
 			case heartBeatMessageType:
 				var t entity.HeartBeat
 				err := json.Unmarshal(msg.Message, &t)
 				if err != nil {
-					logger.Errorf("%+v", err)
+					logger.Errorf("cannot decode heartbeat: %+v", err)
+
+					break
 				}
 
 				outputCh <- t
@@ -93,7 +106,7 @@ func (c *WSClient) Subscribe(ctx context.Context) error {
 	msg := subscribeMessage{
 		MessageType: "subscribe",
 		ProductIDs:  c.tradingPairs,
-		Channels:    []string{"heartbeat", "ticker"},
+		Channels:    c.channels(),
 	}
 
 	return c.makeSubcription(ctx, msg)
@@ -103,12 +116,21 @@ func (c *WSClient) Unsubscribe(ctx context.Context) error {
 	msg := subscribeMessage{
 		MessageType: "unsubscribe",
 		ProductIDs:  c.tradingPairs,
-		Channels:    []string{"heartbeat", "ticker"},
+		Channels:    c.channels(),
 	}
 
 	return c.makeSubcription(ctx, msg)
 }
 
+// channels returns the channels subscribed: the heartbeats are used only for the trading pairs.
+func (c *WSClient) channels() []string {
+	if len(c.venue) > 0 {
+		return []string{"ticker"}
+	}
+
+	return []string{"heartbeat", "ticker"}
+}
+
 func (c *WSClient) makeSubcription(ctx context.Context, msg subscribeMessage) error {
 	b, err := json.Marshal(msg)
 	if err != nil {
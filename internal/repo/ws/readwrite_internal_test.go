@@ -20,7 +20,7 @@ func TestRead(t *testing.T) {
 
 	r := bytes.NewBufferString(heartbeat)
 
-	receivedMsg, err := readWs(r)
+	receivedMsg, err := readWs(r, make([]byte, 1024))
 	assert.Nil(t, err, "err should be nil")
 
 	assert.Equal(t, heartBeatMessageType, receivedMsg.MessageType)
@@ -43,7 +43,7 @@ func TestReadUnkownMessage(t *testing.T) {
 
 	r := bytes.NewBufferString(unknown)
 
-	receivedMsg, err := readWs(r)
+	receivedMsg, err := readWs(r, make([]byte, 1024))
 	assert.Nil(t, err, "err should be nil")
 
 	assert.Equal(t, unknownMessageType, receivedMsg.MessageType)
@@ -59,3 +59,34 @@ func TestWriter(t *testing.T) {
 
 	assert.Equal(t, "hey", w.String())
 }
+
+func TestReadTicker(t *testing.T) {
+	ticker := `{
+    "type": "ticker",
+    "trade_id": 20153558,
+    "sequence": 3262786978,
+    "time": "2017-09-02T17:05:49.250000Z",
+    "product_id": "BTC-USD",
+    "price": "4388.01000000",
+    "side": "buy",
+    "last_size": "0.03000000",
+    "best_bid": "4388",
+    "best_ask": "4388.01"
+}`
+
+	receivedMsg, err := readWs(bytes.NewBufferString(ticker), make([]byte, 1024))
+	assert.Nil(t, err, "err should be nil")
+
+	assert.Equal(t, tickerMessageType, receivedMsg.MessageType)
+
+	var tk entity.Ticker
+	err = json.Unmarshal(receivedMsg.Message, &tk)
+	assert.Nil(t, err)
+
+	assert.Equal(t, int64(3262786978), tk.Sequence)
+	assert.Equal(t, int64(20153558), tk.TradeID)
+	assert.Equal(t, "BTC-USD", tk.ProductID)
+	assert.Equal(t, entity.MustParseDecimal("4388.01"), tk.Price)
+	assert.Equal(t, entity.MustParseDecimal("0.03"), tk.Volume)
+	assert.Equal(t, entity.Buy, tk.Side)
+}
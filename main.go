@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
@@ -9,8 +11,10 @@ import (
 
 	"github.com/tupyy/vwap/internal/compute"
 	"github.com/tupyy/vwap/internal/conf"
+	"github.com/tupyy/vwap/internal/entity"
 	"github.com/tupyy/vwap/internal/log"
 	"github.com/tupyy/vwap/internal/manager"
+	"github.com/tupyy/vwap/internal/repo/checkpoint"
 	"github.com/tupyy/vwap/internal/repo/output"
 	"github.com/tupyy/vwap/internal/repo/ws"
 )
@@ -46,9 +50,58 @@ func main() {
 
 	// setup calculators
 	avgManager := manager.NewAvgManager(out)
+
+	if len(config.AuditFile) > 0 {
+		// the rejections are appended to the previous runs instead of overwriting them
+		auditFile, err := os.OpenFile(config.AuditFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			panic(err)
+		}
+
+		avgManager.SetAuditWriter(output.NewFileWriter(auditFile))
+	}
 	for _, p := range config.TradingPairs {
-		c := compute.NewAvgCalculator(int(config.MaxDataPoints))
-		avgManager.AddAvgCalculator(p, c)
+		if err := addCalculators(avgManager, p, config.Pairs[p]); err != nil {
+			logger.Errorf("cannot setup calculators of %s: %v", p, err)
+			os.Exit(1)
+		}
+	}
+
+	for _, s := range config.SyntheticPairs {
+		avgManager.AddSyntheticPair(manager.SyntheticPair{
+			ProductID: s.ProductID,
+			Legs:      s.Legs,
+			Horizon:   s.Horizon,
+		})
+	}
+
+	for _, c := range config.ConsolidatedPairs {
+		if err := addConsolidatedPair(avgManager, c); err != nil {
+			logger.Errorf("cannot setup consolidated pair %s: %v", c.Symbol, err)
+			os.Exit(1)
+		}
+	}
+
+	for _, c := range config.ArbitrageCycles {
+		err := avgManager.AddArbitrageCycle(manager.ArbitrageCycle{
+			Currencies: c.Currencies,
+			Horizon:    c.Horizon,
+			FeeBps:     c.FeeBps,
+			MinEdgeBps: c.MinEdgeBps,
+		})
+		if err != nil {
+			logger.Errorf("cannot setup arbitrage cycle: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// restore the windows saved before the last shutdown
+	if len(config.Checkpoint.File) > 0 {
+		avgManager.SetCheckpointStore(checkpoint.NewFileStore(config.Checkpoint.File), config.Checkpoint.Interval)
+
+		if err := avgManager.Restore(config.Checkpoint.MaxAge); err != nil {
+			logger.Warningf("checkpoint not restored. starting with empty windows: %v", err)
+		}
 	}
 
 	// dial the connection
@@ -79,6 +132,27 @@ func main() {
 		os.Exit(1)
 	}
 
+	// connect to the feeds of the venues of the consolidated pairs
+	venueClients := make([]*ws.WSClient, 0)
+
+	for _, feed := range venueFeeds(config.ConsolidatedPairs) {
+		feed := feed
+
+		venueConn, venueClient, err := subscribeVenue(feed)
+		if err != nil {
+			logger.Errorf("error subscribing to venue %s: %v", feed.name, err)
+			os.Exit(1)
+		}
+		defer func() {
+			err := venueConn.Close()
+			if err != nil {
+				logger.Errorf("error disconnecting from venue %s: %v", feed.name, err)
+			}
+		}()
+
+		venueClients = append(venueClients, venueClient)
+	}
+
 	// define our context
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -89,6 +163,10 @@ func main() {
 	// start reading
 	errCh := make(chan error)
 	wsClient.Receive(ctx, msgCh, errCh)
+
+	for _, c := range venueClients {
+		c.Receive(ctx, msgCh, errCh)
+	}
 	go func() {
 		for e := range errCh {
 			logger.Errorf("error reading ws: %+v", e)
@@ -116,8 +194,209 @@ func main() {
 
 	// close the client
 	wsClient.Shutdown()
+
+	for _, c := range venueClients {
+		c.Shutdown()
+	}
+
 	logger.Infof("ws reader closed")
 
 	// shutdown usecase
 	avgManager.Shutdown()
+
+	for _, p := range config.TradingPairs {
+		logger.Infof("%s: %d duplicated trades dropped, %d trades rejected by the filters", p, avgManager.DroppedTrades(p), avgManager.RejectedTrades(p))
+	}
+}
+
+// addCalculators adds the calculators of the pair horizons, the indicators and the candle builders to the manager.
+// All the vwap horizons share one calculator. Each twap horizon has its own calculator.
+func addCalculators(avgManager *manager.AvgManager, productID string, pairConf conf.PairConf) error {
+	c := compute.NewTradingPairAvgCalculator()
+	vwapHorizons := 0
+
+	warmUp := compute.WarmUp{
+		MinPoints: pairConf.WarmUp.MinPoints,
+		MinVolume: pairConf.WarmUp.MinVolume,
+		MinSpan:   pairConf.WarmUp.MinSpan,
+	}
+
+	for _, h := range pairConf.Horizons {
+		if h.Method == entity.TWAP {
+			twap := compute.NewTWAPCalculator(h.Name, h.WindowDuration)
+			twap.SetQuoteIncrement(pairConf.QuoteIncrement)
+			twap.SetMarkDegraded(pairConf.MarkDegraded)
+			twap.SetWarmUp(warmUp)
+
+			avgManager.AddAvgCalculator(productID, twap)
+
+			continue
+		}
+
+		vwapHorizons++
+
+		switch {
+		case len(h.Session) > 0:
+			schedule, err := compute.ParseSchedule(h.Session)
+			if err != nil {
+				return err
+			}
+
+			c.AddHorizon(h.Name, compute.NewSessionCalculator(schedule))
+		case h.HalfLife > 0:
+			c.AddHorizon(h.Name, compute.NewDecayCalculator(h.HalfLife))
+		case h.WindowDuration > 0:
+			c.AddHorizon(h.Name, newCalculator(compute.NewTimeCalculator(h.WindowDuration), pairConf))
+		case !h.MaxVolume.IsZero():
+			c.AddHorizon(h.Name, newCalculator(compute.NewVolumeCalculator(h.MaxVolume), pairConf))
+		default:
+			c.AddHorizon(h.Name, newCalculator(compute.NewCalculator(int(h.MaxDataPoints)), pairConf))
+		}
+	}
+
+	avgManager.SetReorderBuffer(productID, pairConf.ReorderSize, pairConf.ReorderDelay)
+
+	if f := pairConf.Filters; !f.MinVolume.IsZero() {
+		avgManager.AddFilter(productID, compute.NewVolumeFilter(f.MinVolume))
+	}
+
+	if f := pairConf.Filters; f.OutlierMADs > 0 || f.OutlierPercent > 0 {
+		window := f.OutlierWindow
+		if window == 0 {
+			window = compute.DefaultOutlierWindow
+		}
+
+		avgManager.AddFilter(productID, compute.NewOutlierFilter(window, f.OutlierMADs, f.OutlierPercent))
+	}
+
+	for _, i := range pairConf.Indicators {
+		indicator, err := compute.NewIndicator(i.Type, i.Name, i.Params)
+		if err != nil {
+			return err
+		}
+
+		avgManager.AddIndicator(productID, indicator)
+	}
+
+	for _, interval := range pairConf.CandleIntervals {
+		avgManager.AddCandleBuilder(productID, compute.NewCandleBuilder(interval))
+	}
+
+	if vwapHorizons == 0 {
+		return nil
+	}
+
+	c.SetQuoteIncrement(pairConf.QuoteIncrement)
+	c.SetBandMultipliers(pairConf.BandMultipliers...)
+	c.SetPercentiles(pairConf.Median, pairConf.Percentiles...)
+	c.SetMarkDegraded(pairConf.MarkDegraded)
+	c.SetWarmUp(warmUp)
+
+	avgManager.AddAvgCalculator(productID, c)
+
+	return nil
+}
+
+// addConsolidatedPair merges the trades of the venues of the pair into one window.
+// The window is a count or volume window: the trades of the venues are not ordered by timestamp.
+func addConsolidatedPair(avgManager *manager.AvgManager, c conf.ConsolidatedPair) error {
+	if c.Window.WindowDuration > 0 {
+		return fmt.Errorf("window_duration is not supported: use max_data_points or max_volume")
+	}
+
+	calc := compute.NewCalculator(int(c.Window.MaxDataPoints))
+	if !c.Window.MaxVolume.IsZero() {
+		calc = compute.NewVolumeCalculator(c.Window.MaxVolume)
+	}
+
+	venues := make([]manager.Venue, 0, len(c.Venues))
+	for _, v := range c.Venues {
+		venues = append(venues, manager.Venue{Name: v.Name, ProductID: v.ProductID, Weight: v.Weight, Excluded: v.Excluded})
+	}
+
+	return avgManager.AddConsolidatedPair(manager.ConsolidatedPair{
+		Symbol:  c.Symbol,
+		Horizon: c.Window.Name,
+		Venues:  venues,
+	}, calc)
+}
+
+// venueFeed is the ws feed of a venue of the consolidated pairs.
+type venueFeed struct {
+	name       string
+	endpoint   string
+	productIDs []string
+}
+
+// venueFeeds returns the feeds of the venues of the consolidated pairs with the products of each venue.
+// A venue has the same endpoint in all the consolidated pairs. The feed of a venue excluded from all of them is not returned.
+func venueFeeds(pairs []conf.ConsolidatedPair) []*venueFeed {
+	feeds := make([]*venueFeed, 0)
+	byName := make(map[string]*venueFeed)
+
+	for _, p := range pairs {
+		for _, v := range p.Venues {
+			if v.Excluded {
+				continue
+			}
+
+			feed, found := byName[v.Name]
+			if !found {
+				feed = &venueFeed{name: v.Name, endpoint: v.Endpoint}
+				byName[v.Name] = feed
+				feeds = append(feeds, feed)
+			}
+
+			if !hasProduct(feed.productIDs, v.ProductID) {
+				feed.productIDs = append(feed.productIDs, v.ProductID)
+			}
+		}
+	}
+
+	return feeds
+}
+
+func hasProduct(productIDs []string, productID string) bool {
+	for _, p := range productIDs {
+		if p == productID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// subscribeVenue connects to the feed of the venue and subscribes to the tickers of its products.
+// The tickers received are marked with the name of the venue.
+func subscribeVenue(feed *venueFeed) (io.Closer, *ws.WSClient, error) {
+	connectCtx, connectCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer connectCancel()
+
+	conn, err := ws.Connect(connectCtx, feed.endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := ws.NewClient(conn, feed.productIDs)
+	c.SetVenue(feed.name)
+
+	subscribeCtx, subscribeCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer subscribeCancel()
+
+	if err := c.Subscribe(subscribeCtx); err != nil {
+		_ = conn.Close()
+
+		return nil, nil, err
+	}
+
+	return conn, c, nil
+}
+
+// newCalculator enables the order statistics of the calculator if the pair reports the median or percentiles.
+func newCalculator(c *compute.Calculator, pairConf conf.PairConf) *compute.Calculator {
+	if pairConf.Median || len(pairConf.Percentiles) > 0 {
+		c.EnableOrderStatistics()
+	}
+
+	return c
 }